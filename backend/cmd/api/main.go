@@ -9,6 +9,8 @@ import (
 
 	"grailify/internal/database"
 	"grailify/internal/handler"
+	"grailify/internal/market"
+	"grailify/internal/payment"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang-jwt/jwt/v5"
@@ -38,7 +40,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key")
 		
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -91,9 +93,19 @@ func main() {
 	db := database.InitDB()
 	defer database.CloseDB(db)
 
+	paymentProvider := payment.WithTimeout(payment.NewFakeProvider(), payment.DefaultTimeout)
+	marketEngine := &market.Engine{DB: db, Payments: paymentProvider}
+
 	authHandler := &handler.AuthHandler{DB: db}
-	itemsHandler := &handler.ItemsHandler{DB: db}
-	profileHandler := &handler.ProfileHandler{DB: db}
+	itemsHandler := &handler.ItemsHandler{DB: db, Market: marketEngine, Stats: &market.Stats{DB: db}}
+	profileHandler := &handler.ProfileHandler{DB: db, Payments: paymentProvider}
+	ordersHandler := &handler.OrdersHandler{DB: db, Payments: paymentProvider}
+	sellerHandler := &handler.SellerHandler{DB: db}
+	bidsHandler := &handler.BidsHandler{DB: db, Market: marketEngine}
+	exchangeRatesHandler := &handler.ExchangeRatesHandler{DB: db}
+	shipmentsHandler := &handler.ShipmentsHandler{DB: db}
+	authenticationHandler := &handler.AuthenticationHandler{DB: db, Payments: paymentProvider}
+	cartHandler := &handler.CartHandler{DB: db}
 
 	r := mux.NewRouter()
 	r.Use(corsMiddleware)
@@ -102,8 +114,12 @@ func main() {
 	r.HandleFunc("/api/login", authHandler.Login).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/items", itemsHandler.GetAllItems).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/item", itemsHandler.GetItemByID).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/items/{id:[0-9]+}/orderbook", itemsHandler.GetOrderBook).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/items/{id:[0-9]+}/price-history", itemsHandler.GetPriceHistory).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/items/{id:[0-9]+}/stats", itemsHandler.GetItemStats).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/search", itemsHandler.SearchItems).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/categories", itemsHandler.GetAllCategories).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/exchange-rates", exchangeRatesHandler.GetRates).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/trending", itemsHandler.GetTrendingItems).Methods("GET", "OPTIONS")
 	r.HandleFunc("/api/sell-page-items", itemsHandler.GetSellPageData).Methods("GET", "OPTIONS")
 
@@ -117,10 +133,34 @@ func main() {
 	api.HandleFunc("/addresses/{id:[0-9]+}", profileHandler.DeleteAddress).Methods("DELETE", "OPTIONS")
 	api.HandleFunc("/payment-methods", profileHandler.AddPaymentMethod).Methods("POST", "OPTIONS")
 	api.HandleFunc("/payment-methods/{id:[0-9]+}", profileHandler.DeletePaymentMethod).Methods("DELETE", "OPTIONS")
-	api.HandleFunc("/orders", profileHandler.CreateOrder).Methods("POST", "OPTIONS")
-	api.HandleFunc("/listings", itemsHandler.CreateListing).Methods("POST", "OPTIONS")
+	api.HandleFunc("/cart", cartHandler.GetCart).Methods("GET", "OPTIONS")
+	api.HandleFunc("/cart", cartHandler.AddToCart).Methods("POST", "OPTIONS")
+	api.HandleFunc("/cart", cartHandler.ClearCart).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/cart/items/{id:[0-9]+}", cartHandler.UpdateCartItem).Methods("PATCH", "OPTIONS")
+	api.HandleFunc("/cart/items/{id:[0-9]+}", cartHandler.RemoveCartItem).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/checkout/quote", profileHandler.QuoteCheckout).Methods("POST", "OPTIONS")
+	api.HandleFunc("/orders", handler.Idempotent(db, profileHandler.CreateOrder)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/orders", ordersHandler.ListOrders).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders/{id:[0-9]+}", ordersHandler.GetOrder).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders/{id:[0-9]+}/transitions", ordersHandler.TransitionOrder).Methods("POST", "OPTIONS")
+	api.HandleFunc("/orders/{id:[0-9]+}/refunds", ordersHandler.CreateRefund).Methods("POST", "OPTIONS")
+	api.HandleFunc("/orders/{id:[0-9]+}/shipments", shipmentsHandler.ShipOrder).Methods("POST", "OPTIONS")
+	api.HandleFunc("/shipments/{id:[0-9]+}/events", shipmentsHandler.AddEvent).Methods("POST", "OPTIONS")
+	api.HandleFunc("/authentication/queue", authenticationHandler.GetQueue).Methods("GET", "OPTIONS")
+	api.HandleFunc("/order-items/{id:[0-9]+}/authentication", authenticationHandler.RecordVerdict).Methods("POST", "OPTIONS")
+	api.HandleFunc("/listings", handler.Idempotent(db, itemsHandler.CreateListing)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/listings/fee-preview", itemsHandler.GetFeePreview).Methods("GET", "OPTIONS")
 	api.HandleFunc("/listings/{id:[0-9]+}", itemsHandler.UpdateListing).Methods("PUT", "OPTIONS")
 	api.HandleFunc("/listings/{id:[0-9]+}", itemsHandler.DeleteListing).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/bids", handler.Idempotent(db, bidsHandler.CreateBid)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/bids", bidsHandler.ListBids).Methods("GET", "OPTIONS")
+	api.HandleFunc("/bids/{id:[0-9]+}", bidsHandler.CancelBid).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/seller/balance", sellerHandler.GetBalance).Methods("GET", "OPTIONS")
+	api.HandleFunc("/seller/ledger", sellerHandler.GetLedger).Methods("GET", "OPTIONS")
+	api.HandleFunc("/seller/sales", sellerHandler.GetSales).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sellers/{id:[0-9]+}/payouts", sellerHandler.CreatePayout).Methods("POST", "OPTIONS")
+	api.HandleFunc("/seller/orders/{id:[0-9]+}/shipments", shipmentsHandler.ShipSale).Methods("POST", "OPTIONS")
+	api.HandleFunc("/exchange-rates", exchangeRatesHandler.UploadRates).Methods("PUT", "OPTIONS")
 
 	log.Println("Starting Grailify server on http://localhost:8080")
 	if err := http.ListenAndServe(":8080", r); err != nil {
@@ -0,0 +1,208 @@
+package authentication
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/shipping"
+)
+
+const (
+	VerdictPass = "pass"
+	VerdictFail = "fail"
+)
+
+var ErrInvalidVerdict = errors.New("authentication: verdict must be pass or fail")
+
+func ParseVerdict(s string) (string, error) {
+	switch s {
+	case VerdictPass, VerdictFail:
+		return s, nil
+	}
+	return "", ErrInvalidVerdict
+}
+
+// Inspectable reports whether lines of an order in status s can be inspected.
+func Inspectable(s order.Status) bool {
+	return s == order.StatusPaid || s == order.StatusAuthenticating || s == order.StatusPartiallyRefunded
+}
+
+// Record saves a verdict and its photos and fills in a.ID.
+func Record(tx *sql.Tx, a *model.Authentication) error {
+	var notes sql.NullString
+	if a.Notes != "" {
+		notes = sql.NullString{String: a.Notes, Valid: true}
+	}
+	var authenticator, refund, returnShipment sql.NullInt64
+	if a.AuthenticatorID > 0 {
+		authenticator = sql.NullInt64{Int64: int64(a.AuthenticatorID), Valid: true}
+	}
+	if a.RefundID > 0 {
+		refund = sql.NullInt64{Int64: int64(a.RefundID), Valid: true}
+	}
+	if a.ReturnShipmentID > 0 {
+		returnShipment = sql.NullInt64{Int64: int64(a.ReturnShipmentID), Valid: true}
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO authentications (order_item_id, verdict, notes, authenticator_user_id, refund_id, return_shipment_id) VALUES (?, ?, ?, ?, ?, ?)",
+		a.OrderItemID, a.Verdict, notes, authenticator, refund, returnShipment,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+
+	for _, url := range a.Photos {
+		if _, err := tx.Exec("INSERT INTO authentication_photos (authentication_id, url) VALUES (?, ?)", a.ID, url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verdict returns the verdict already given on a line, or "" if it has not
+// been inspected.
+func Verdict(tx *sql.Tx, orderItemID int) (string, error) {
+	var verdict string
+	err := tx.QueryRow("SELECT verdict FROM authentications WHERE order_item_id = ?", orderItemID).Scan(&verdict)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return verdict, err
+}
+
+// Pending counts the lines of an order that still have to pass before it
+// can ship to the buyer. Refunded lines are not counted.
+func Pending(tx *sql.Tx, orderID int) (int, error) {
+	var pending int
+	err := tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM order_items oi
+		LEFT JOIN authentications a ON a.order_item_id = oi.id
+		WHERE oi.order_id = ? AND oi.refunded_quantity < oi.quantity AND (a.id IS NULL OR a.verdict <> ?)
+	`, orderID, VerdictPass).Scan(&pending)
+	return pending, err
+}
+
+// queueFrom selects lines with no verdict yet that are with us: store stock,
+// or seller stock whose inbound parcel has been sent.
+const queueFrom = `
+	FROM order_items oi
+	JOIN orders o ON oi.order_id = o.id
+	JOIN items i ON oi.item_id = i.id
+	LEFT JOIN (shipment_items si JOIN shipments sh ON sh.id = si.shipment_id AND sh.kind = ?) ON si.order_item_id = oi.id
+	LEFT JOIN authentications a ON a.order_item_id = oi.id
+	WHERE a.id IS NULL AND oi.refunded_quantity < oi.quantity
+		AND o.status IN (?, ?, ?)
+		AND (oi.seller_user_id IS NULL OR sh.id IS NOT NULL)
+`
+
+// Queue lists lines awaiting inspection, the longest waiting first, and how
+// many there are in total.
+func Queue(db *sql.DB, limit, offset int) ([]model.AuthenticationQueueItem, int, error) {
+	args := []interface{}{shipping.KindInbound, string(order.StatusPaid), string(order.StatusAuthenticating), string(order.StatusPartiallyRefunded)}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) "+queueFrom, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT oi.id, oi.order_id, oi.item_id, i.name, i.image_url, oi.size, oi.seller_user_id,
+			sh.carrier, sh.tracking_number, sh.created_at, o.created_at
+		`+queueFrom+`
+		ORDER BY COALESCE(sh.created_at, o.created_at) ASC, oi.id ASC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	queue := []model.AuthenticationQueueItem{}
+	for rows.Next() {
+		var q model.AuthenticationQueueItem
+		var imageURL, size, carrier, trackingNumber sql.NullString
+		var sellerID sql.NullInt64
+		var shippedAt sql.NullTime
+		if err := rows.Scan(&q.OrderItemID, &q.OrderID, &q.ItemID, &q.ItemName, &imageURL, &size, &sellerID,
+			&carrier, &trackingNumber, &shippedAt, &q.OrderedAt); err != nil {
+			return nil, 0, err
+		}
+		q.ItemImageURL = imageURL.String
+		q.Size = size.String
+		q.SellerID = int(sellerID.Int64)
+		q.Carrier = carrier.String
+		q.TrackingNumber = trackingNumber.String
+		if shippedAt.Valid {
+			q.ShippedAt = &shippedAt.Time
+		}
+		queue = append(queue, q)
+	}
+	return queue, total, rows.Err()
+}
+
+// ForOrder returns the verdicts on an order's lines with their photos.
+func ForOrder(db *sql.DB, orderID int) ([]model.Authentication, error) {
+	rows, err := db.Query(`
+		SELECT a.id, a.order_item_id, a.verdict, a.notes, a.authenticator_user_id, a.refund_id, a.return_shipment_id, a.created_at
+		FROM authentications a
+		JOIN order_items oi ON a.order_item_id = oi.id
+		WHERE oi.order_id = ?
+		ORDER BY a.created_at ASC, a.id ASC
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	authentications := []model.Authentication{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var a model.Authentication
+		var notes sql.NullString
+		var authenticator, refund, returnShipment sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.OrderItemID, &a.Verdict, &notes, &authenticator, &refund, &returnShipment, &a.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		a.Notes = notes.String
+		a.AuthenticatorID = int(authenticator.Int64)
+		a.RefundID = int(refund.Int64)
+		a.ReturnShipmentID = int(returnShipment.Int64)
+		a.Photos = []string{}
+		index[a.ID] = len(authentications)
+		authentications = append(authentications, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(authentications) == 0 {
+		return authentications, err
+	}
+
+	ids := make([]interface{}, len(authentications))
+	for i, a := range authentications {
+		ids[i] = a.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	photoRows, err := db.Query("SELECT authentication_id, url FROM authentication_photos WHERE authentication_id IN ("+placeholders+") ORDER BY id", ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer photoRows.Close()
+	for photoRows.Next() {
+		var authenticationID int
+		var url string
+		if err := photoRows.Scan(&authenticationID, &url); err != nil {
+			return nil, err
+		}
+		a := &authentications[index[authenticationID]]
+		a.Photos = append(a.Photos, url)
+	}
+	return authentications, photoRows.Err()
+}
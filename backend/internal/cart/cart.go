@@ -0,0 +1,320 @@
+package cart
+
+import (
+	"database/sql"
+	"errors"
+
+	"grailify/internal/database"
+	"grailify/internal/model"
+	"grailify/internal/pricing"
+)
+
+const (
+	LineAvailable    = "available"
+	LinePriceChanged = "price_changed"
+	LineLowStock     = "insufficient_stock"
+	LineSoldOut      = "sold_out"
+	LineUnavailable  = "unavailable"
+)
+
+var (
+	ErrListingNotFound = errors.New("cart: listing not found")
+	ErrLineNotFound    = errors.New("cart: line not found")
+	ErrAlreadyInCart   = errors.New("cart: listing already in cart")
+	ErrInvalidQuantity = errors.New("cart: quantity must be at least 1")
+	ErrNotEnoughStock  = errors.New("cart: not enough stock")
+)
+
+// cartID returns the id of the user's cart, creating it if needed.
+func cartID(q database.Querier, userID int) (int, error) {
+	if _, err := q.Exec("INSERT IGNORE INTO carts (user_id) VALUES (?)", userID); err != nil {
+		return 0, err
+	}
+	var id int
+	err := q.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&id)
+	return id, err
+}
+
+type listing struct {
+	ItemID   int
+	Price    model.Money
+	Currency string
+	Stock    int
+}
+
+// currentListing returns a listing's price as buyers see it now.
+func currentListing(q database.Querier, inventoryID int) (listing, error) {
+	var l listing
+	var categoryID int
+	err := q.QueryRow(`
+		SELECT ii.item_id, i.category_id, ii.price, ii.currency, ii.stock
+		FROM item_inventory ii
+		JOIN items i ON ii.item_id = i.id
+		WHERE ii.id = ?
+	`, inventoryID).Scan(&l.ItemID, &categoryID, &l.Price, &l.Currency, &l.Stock)
+	if err == sql.ErrNoRows {
+		return l, ErrListingNotFound
+	}
+	if err != nil {
+		return l, err
+	}
+	rounding, err := pricing.PolicyFor(q, categoryID, l.Currency)
+	if err != nil {
+		return l, err
+	}
+	l.Price = rounding.Apply(l.Price.In(l.Currency))
+	return l, nil
+}
+
+// Add puts units of a listing in the user's cart at its current price. A
+// listing already in the cart has the units added to its line.
+func Add(q database.Querier, userID, inventoryID, quantity int) error {
+	if quantity < 1 {
+		return ErrInvalidQuantity
+	}
+	l, err := currentListing(q, inventoryID)
+	if err != nil {
+		return err
+	}
+	id, err := cartID(q, userID)
+	if err != nil {
+		return err
+	}
+	var existing int
+	err = q.QueryRow("SELECT quantity FROM cart_items WHERE cart_id = ? AND inventory_id = ?", id, inventoryID).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	quantity += existing
+	if quantity > l.Stock {
+		return ErrNotEnoughStock
+	}
+	_, err = q.Exec(`
+		INSERT INTO cart_items (cart_id, item_id, inventory_id, quantity, saved_price, currency) VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), saved_price = VALUES(saved_price), currency = VALUES(currency)
+	`, id, l.ItemID, inventoryID, quantity, l.Price, l.Currency)
+	return err
+}
+
+// Update points a line at a listing, which may be the one it already has,
+// sets its quantity and saves the listing's current price on it.
+func Update(q database.Querier, userID, lineID, inventoryID, quantity int) error {
+	if quantity < 1 {
+		return ErrInvalidQuantity
+	}
+	l, err := currentListing(q, inventoryID)
+	if err != nil {
+		return err
+	}
+	if quantity > l.Stock {
+		return ErrNotEnoughStock
+	}
+	var existing int
+	err = q.QueryRow(`
+		SELECT ci.id FROM cart_items ci
+		JOIN carts c ON ci.cart_id = c.id
+		WHERE c.user_id = ? AND ci.inventory_id = ? AND ci.id <> ?
+	`, userID, inventoryID, lineID).Scan(&existing)
+	if err == nil {
+		return ErrAlreadyInCart
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	result, err := q.Exec(`
+		UPDATE cart_items ci
+		JOIN carts c ON ci.cart_id = c.id
+		SET ci.item_id = ?, ci.inventory_id = ?, ci.quantity = ?, ci.saved_price = ?, ci.currency = ?
+		WHERE ci.id = ? AND c.user_id = ?
+	`, l.ItemID, inventoryID, quantity, l.Price, l.Currency, lineID, userID)
+	if err != nil {
+		return err
+	}
+	return requireRow(q, result, userID, lineID)
+}
+
+func Remove(q database.Querier, userID, lineID int) error {
+	result, err := q.Exec(`
+		DELETE ci FROM cart_items ci
+		JOIN carts c ON ci.cart_id = c.id
+		WHERE ci.id = ? AND c.user_id = ?
+	`, lineID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLineNotFound
+	}
+	return nil
+}
+
+func Clear(q database.Querier, userID int) error {
+	_, err := q.Exec("DELETE ci FROM cart_items ci JOIN carts c ON ci.cart_id = c.id WHERE c.user_id = ?", userID)
+	return err
+}
+
+// RemoveListings drops the given listings from the user's cart, e.g. once
+// they have been ordered.
+func RemoveListings(q database.Querier, userID int, inventoryIDs []int) error {
+	for _, id := range inventoryIDs {
+		if _, err := q.Exec("DELETE ci FROM cart_items ci JOIN carts c ON ci.cart_id = c.id WHERE c.user_id = ? AND ci.inventory_id = ?", userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge adds the lines of a cart kept by a logged-out browser. Lines for
+// listings that no longer exist or lack the stock are skipped.
+func Merge(q database.Querier, userID int, items []model.CartItem) error {
+	for _, item := range items {
+		err := Add(q, userID, item.InventoryID, max(item.Quantity, 1))
+		if err != nil && err != ErrListingNotFound && err != ErrNotEnoughStock {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireRow reports ErrLineNotFound when an update matched no line. MySQL
+// counts unchanged rows as unaffected, so a zero count is double-checked.
+func requireRow(q database.Querier, result sql.Result, userID, lineID int) error {
+	n, err := result.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	var id int
+	err = q.QueryRow("SELECT ci.id FROM cart_items ci JOIN carts c ON ci.cart_id = c.id WHERE ci.id = ? AND c.user_id = ?", lineID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrLineNotFound
+	}
+	return err
+}
+
+// Lines reads the user's cart and checks every line against its listing.
+func Lines(q database.Querier, userID int) ([]model.CartLine, error) {
+	rounding, err := pricing.Load(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`
+		SELECT ci.id, ci.item_id, ci.inventory_id, i.name, i.brand, i.image_url, i.category_id, s.size_value,
+			ci.quantity, ci.saved_price, ci.currency, ii.price, ii.currency, ii.stock, ci.added_at
+		FROM cart_items ci
+		JOIN carts c ON ci.cart_id = c.id
+		JOIN items i ON ci.item_id = i.id
+		LEFT JOIN item_inventory ii ON ci.inventory_id = ii.id
+		LEFT JOIN sizes s ON ii.size_id = s.id
+		WHERE c.user_id = ?
+		ORDER BY ci.added_at ASC, ci.id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []model.CartLine{}
+	for rows.Next() {
+		var line model.CartLine
+		var inventoryID, stock sql.NullInt64
+		var imageURL, sizeValue, listingCurrency sql.NullString
+		var categoryID int
+		var price model.NullMoney
+		if err := rows.Scan(&line.ID, &line.ItemID, &inventoryID, &line.Name, &line.Brand, &imageURL, &categoryID, &sizeValue,
+			&line.Quantity, &line.SavedPrice, &line.Currency, &price, &listingCurrency, &stock, &line.AddedAt); err != nil {
+			return nil, err
+		}
+		line.ImageURL = imageURL.String
+		line.SavedPrice.Currency = line.Currency
+		line.Price = line.SavedPrice
+		line.LineTotal = line.SavedPrice.Mul(line.Quantity)
+
+		if !inventoryID.Valid {
+			line.Status = LineUnavailable
+			lines = append(lines, line)
+			continue
+		}
+		line.InventoryID = int(inventoryID.Int64)
+		line.Size = "One Size"
+		if sizeValue.Valid {
+			line.Size = sizeValue.String
+		}
+		line.Currency = listingCurrency.String
+		line.Price = rounding.For(categoryID, line.Currency).Apply(price.Money.In(line.Currency))
+		line.LineTotal = line.Price.Mul(line.Quantity)
+		line.Stock = int(stock.Int64)
+
+		switch {
+		case line.Stock <= 0:
+			line.Status = LineSoldOut
+		case line.Stock < line.Quantity:
+			line.Status = LineLowStock
+		case line.Price != line.SavedPrice:
+			line.Status = LinePriceChanged
+		default:
+			line.Status = LineAvailable
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// Load returns the user's cart with its subtotal.
+func Load(q database.Querier, userID int) (model.Cart, error) {
+	lines, err := Lines(q, userID)
+	if err != nil {
+		return model.Cart{}, err
+	}
+	c := model.Cart{Items: lines}
+	for i, line := range lines {
+		if i == 0 {
+			c.Currency = line.Currency
+		} else if line.Currency != c.Currency {
+			c.Currency = ""
+			break
+		}
+	}
+	c.Subtotal = model.Cents(0, c.Currency)
+	if c.Currency == "" && len(lines) > 0 {
+		return c, nil
+	}
+	for _, line := range lines {
+		if line.Status == LineAvailable || line.Status == LinePriceChanged {
+			c.Subtotal = c.Subtotal.Add(line.LineTotal)
+		}
+	}
+	return c, nil
+}
+
+// CheckoutItems turns the lines that can still be bought into checkout cart
+// items at their saved prices, so checkout flags any price change. Lines
+// that are sold out or no longer listed are left out rather than failing the
+// whole checkout; the cart still shows them with their status.
+func CheckoutItems(q database.Querier, userID int) ([]model.CartItem, error) {
+	lines, err := Lines(q, userID)
+	if err != nil {
+		return nil, err
+	}
+	var items []model.CartItem
+	for _, line := range lines {
+		if line.Status == LineUnavailable || line.Status == LineSoldOut {
+			continue
+		}
+		items = append(items, model.CartItem{
+			ID:          line.ItemID,
+			InventoryID: line.InventoryID,
+			Name:        line.Name,
+			Brand:       line.Brand,
+			Size:        line.Size,
+			Price:       line.SavedPrice,
+			Currency:    line.SavedPrice.Currency,
+			ImageURL:    line.ImageURL,
+			Quantity:    line.Quantity,
+		})
+	}
+	return items, nil
+}
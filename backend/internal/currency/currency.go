@@ -0,0 +1,95 @@
+package currency
+
+import (
+	"errors"
+	"strings"
+
+	"grailify/internal/database"
+	"grailify/internal/model"
+)
+
+// Base is the currency the platform keeps its books in. Retail prices,
+// price_history and the seller ledger are all in Base; listings and orders
+// carry their own currency.
+const Base = "USD"
+
+var (
+	ErrInvalidCode     = errors.New("currency: invalid currency code")
+	ErrUnknownCurrency = errors.New("currency: no exchange rate for currency")
+)
+
+// Normalize upper-cases an ISO 4217 code. An empty code means Base.
+func Normalize(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return Base, nil
+	}
+	if len(code) != 3 {
+		return "", ErrInvalidCode
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "", ErrInvalidCode
+		}
+	}
+	return code, nil
+}
+
+// Rates holds the exchange_rates table: how many units of each currency buy
+// one unit of Base.
+type Rates struct {
+	perBase map[string]float64
+}
+
+// NewRates builds Rates from units of each currency per unit of Base.
+func NewRates(perBase map[string]float64) Rates {
+	rates := Rates{perBase: map[string]float64{Base: 1}}
+	for code, rate := range perBase {
+		if code != Base && rate > 0 {
+			rates.perBase[code] = rate
+		}
+	}
+	return rates
+}
+
+func Load(q database.Querier) (Rates, error) {
+	rates := Rates{perBase: map[string]float64{Base: 1}}
+	rows, err := q.Query("SELECT currency, rate FROM exchange_rates")
+	if err != nil {
+		return rates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var rate float64
+		if err := rows.Scan(&code, &rate); err != nil {
+			return rates, err
+		}
+		if code != Base && rate > 0 {
+			rates.perBase[code] = rate
+		}
+	}
+	return rates, rows.Err()
+}
+
+func (r Rates) Has(code string) bool {
+	_, ok := r.perBase[code]
+	return ok
+}
+
+// Convert turns an amount into another currency, rounded to the cent.
+func (r Rates) Convert(amount model.Money, to string) (model.Money, error) {
+	if amount.Currency == to {
+		return amount, nil
+	}
+	fromRate, ok := r.perBase[amount.Currency]
+	if !ok {
+		return model.Money{}, ErrUnknownCurrency
+	}
+	toRate, ok := r.perBase[to]
+	if !ok {
+		return model.Money{}, ErrUnknownCurrency
+	}
+	return amount.MulRate(toRate / fromRate).In(to), nil
+}
@@ -0,0 +1,11 @@
+package database
+
+import "database/sql"
+
+// Querier is satisfied by both *sql.DB and *sql.Tx, so the same code can run
+// on its own or inside a caller's transaction.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
@@ -0,0 +1,70 @@
+package fees
+
+import (
+	"database/sql"
+
+	"grailify/internal/currency"
+	"grailify/internal/database"
+	"grailify/internal/model"
+)
+
+// Schedule is what the platform charges a seller for one sale: a percentage
+// commission on the sale price plus a fixed processing fee. category_fees
+// keeps the processing fee in the base currency.
+type Schedule struct {
+	CommissionRate float64
+	ProcessingFee  model.Money
+}
+
+// DefaultSchedule applies when category_fees has no matching row.
+var DefaultSchedule = Schedule{CommissionRate: 0.10, ProcessingFee: model.Cents(0, currency.Base)}
+
+// ScheduleFor returns the fee schedule for a category, falling back to the
+// default row (category_id IS NULL) and then to DefaultSchedule.
+func ScheduleFor(q database.Querier, categoryID int) (Schedule, error) {
+	s := Schedule{ProcessingFee: model.Cents(0, currency.Base)}
+	err := q.QueryRow(`
+		SELECT commission_rate, processing_fee FROM category_fees
+		WHERE category_id = ? OR category_id IS NULL
+		ORDER BY category_id IS NULL
+		LIMIT 1
+	`, categoryID).Scan(&s.CommissionRate, &s.ProcessingFee)
+	if err == sql.ErrNoRows {
+		return DefaultSchedule, nil
+	}
+	if err != nil {
+		return Schedule{}, err
+	}
+	return s, nil
+}
+
+// In converts the processing fee to code, for quoting prices in that
+// currency.
+func (s Schedule) In(rates currency.Rates, code string) (Schedule, error) {
+	fee, err := rates.Convert(s.ProcessingFee, code)
+	if err != nil {
+		return Schedule{}, err
+	}
+	s.ProcessingFee = fee
+	return s, nil
+}
+
+// Quote breaks a sale price down into fees and the seller's net payout. The
+// processing fee must already be in the price currency; see In. Fees never
+// exceed the price itself.
+func (s Schedule) Quote(price model.Money) model.FeeBreakdown {
+	commission := price.MulRate(s.CommissionRate)
+	processingFee := s.ProcessingFee
+	total := commission.Add(processingFee)
+	if total.Amount > price.Amount {
+		total = price
+	}
+	return model.FeeBreakdown{
+		Price:          price,
+		CommissionRate: s.CommissionRate,
+		Commission:     commission,
+		ProcessingFee:  processingFee,
+		TotalFees:      total,
+		NetPayout:      price.Sub(total),
+	}
+}
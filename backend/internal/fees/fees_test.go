@@ -0,0 +1,89 @@
+package fees
+
+import (
+	"testing"
+
+	"grailify/internal/currency"
+	"grailify/internal/model"
+)
+
+func TestQuote(t *testing.T) {
+	rates := currency.NewRates(map[string]float64{"EUR": 0.9, "GBP": 0.8})
+	tests := []struct {
+		name          string
+		schedule      Schedule
+		price         model.Money
+		commission    int64
+		processingFee int64
+		totalFees     int64
+		netPayout     int64
+	}{
+		{
+			name:       "default schedule",
+			schedule:   DefaultSchedule,
+			price:      model.Cents(10000, "USD"),
+			commission: 1000, totalFees: 1000, netPayout: 9000,
+		},
+		{
+			name:       "commission and processing fee",
+			schedule:   Schedule{CommissionRate: 0.10, ProcessingFee: model.Cents(30, "USD")},
+			price:      model.Cents(10000, "USD"),
+			commission: 1000, processingFee: 30, totalFees: 1030, netPayout: 8970,
+		},
+		{
+			name:       "commission rounds to the nearest cent",
+			schedule:   Schedule{CommissionRate: 0.125, ProcessingFee: model.Cents(0, "USD")},
+			price:      model.Cents(999, "USD"),
+			commission: 125, totalFees: 125, netPayout: 874,
+		},
+		{
+			name:       "fees capped at the price",
+			schedule:   Schedule{CommissionRate: 0.10, ProcessingFee: model.Cents(500, "USD")},
+			price:      model.Cents(300, "USD"),
+			commission: 30, processingFee: 500, totalFees: 300, netPayout: 0,
+		},
+		{
+			name:       "zero price",
+			schedule:   Schedule{CommissionRate: 0.10, ProcessingFee: model.Cents(30, "USD")},
+			price:      model.Cents(0, "USD"),
+			commission: 0, processingFee: 30, totalFees: 0, netPayout: 0,
+		},
+		{
+			name:       "processing fee converted to euros",
+			schedule:   Schedule{CommissionRate: 0.05, ProcessingFee: model.Cents(30, "USD")},
+			price:      model.Cents(20000, "EUR"),
+			commission: 1000, processingFee: 27, totalFees: 1027, netPayout: 18973,
+		},
+		{
+			name:       "processing fee converted to pounds",
+			schedule:   Schedule{CommissionRate: 0.10, ProcessingFee: model.Cents(25, "USD")},
+			price:      model.Cents(5000, "GBP"),
+			commission: 500, processingFee: 20, totalFees: 520, netPayout: 4480,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := tt.price.Currency
+			schedule, err := tt.schedule.In(rates, code)
+			if err != nil {
+				t.Fatalf("In(%s) error = %v", code, err)
+			}
+			got := schedule.Quote(tt.price)
+			want := model.FeeBreakdown{
+				Price:          tt.price,
+				CommissionRate: tt.schedule.CommissionRate,
+				Commission:     model.Cents(tt.commission, code),
+				ProcessingFee:  model.Cents(tt.processingFee, code),
+				TotalFees:      model.Cents(tt.totalFees, code),
+				NetPayout:      model.Cents(tt.netPayout, code),
+			}
+			if got != want {
+				t.Errorf("Quote(%s) = %+v, want %+v", tt.price, got, want)
+			}
+		})
+	}
+
+	if _, err := DefaultSchedule.In(rates, "JPY"); err != currency.ErrUnknownCurrency {
+		t.Errorf("In(JPY) error = %v, want ErrUnknownCurrency", err)
+	}
+}
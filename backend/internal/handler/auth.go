@@ -3,12 +3,14 @@ package handler
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings" 
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"grailify/internal/cart"
 	"grailify/internal/model"
 )
 
@@ -22,6 +24,9 @@ type Credentials struct {
 	Username string `json:"username,omitempty"` 
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// CartItems is the cart kept by the browser before logging in. It is
+	// merged into the stored cart on login.
+	CartItems []model.CartItem `json:"cartItems,omitempty"`
 }
 
 type Claims struct {
@@ -111,6 +116,13 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(creds.CartItems) > 0 {
+		// A cart that fails to merge should not keep the user from logging in.
+		if err := cart.Merge(h.DB, user.ID, creds.CartItems); err != nil {
+			log.Printf("Failed to merge cart for user %d: %v", user.ID, err)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
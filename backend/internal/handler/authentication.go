@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"grailify/internal/authentication"
+	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/payment"
+	"grailify/internal/shipping"
+)
+
+type AuthenticationHandler struct {
+	DB       *sql.DB
+	Payments payment.Provider
+}
+
+type VerdictPayload struct {
+	Verdict string   `json:"verdict"`
+	Notes   string   `json:"notes"`
+	Photos  []string `json:"photos"`
+	// The return parcel for a failed seller item.
+	ReturnCarrier        string `json:"returnCarrier"`
+	ReturnTrackingNumber string `json:"returnTrackingNumber"`
+}
+
+type AuthenticationQueueResponse struct {
+	Items      []model.AuthenticationQueueItem `json:"items"`
+	TotalPages int                             `json:"totalPages"`
+	Page       int                             `json:"page"`
+}
+
+// mayAuthenticate checks the caller is an authenticator or staff, writing
+// the error response if not.
+func (h *AuthenticationHandler) mayAuthenticate(w http.ResponseWriter, userID int) bool {
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if role != roleAuthenticator && role != roleStaff {
+		http.Error(w, "Only authenticators can inspect items", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (h *AuthenticationHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.mayAuthenticate(w, userID) {
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit := 50
+
+	items, total, err := authentication.Queue(h.DB, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Failed to load authentication queue: %v", err)
+		http.Error(w, "Failed to load authentication queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthenticationQueueResponse{
+		Items:      items,
+		TotalPages: (total + limit - 1) / limit,
+		Page:       page,
+	})
+}
+
+// RecordVerdict records the inspection of one sold line. A failed line is
+// refunded to the buyer without returning it to stock, and seller items are
+// sent back to the seller. A paid order with no seller items still to arrive
+// moves to authenticating.
+func (h *AuthenticationHandler) RecordVerdict(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderItemID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid order item ID", http.StatusBadRequest)
+		return
+	}
+	if !h.mayAuthenticate(w, userID) {
+		return
+	}
+
+	var payload VerdictPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	verdict, err := authentication.ParseVerdict(payload.Verdict)
+	if err != nil {
+		http.Error(w, "Verdict must be pass or fail", http.StatusBadRequest)
+		return
+	}
+	record := model.Authentication{
+		OrderItemID:     orderItemID,
+		Verdict:         verdict,
+		Notes:           strings.TrimSpace(payload.Notes),
+		Photos:          []string{},
+		AuthenticatorID: userID,
+	}
+	for _, url := range payload.Photos {
+		if url = strings.TrimSpace(url); url != "" {
+			record.Photos = append(record.Photos, url)
+		}
+	}
+	returnParcel := ShipmentPayload{Carrier: payload.ReturnCarrier, TrackingNumber: payload.ReturnTrackingNumber}
+	hasReturnParcel := returnParcel.valid()
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	var orderID, quantity, refundedQuantity int
+	var sellerID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT order_id, quantity, refunded_quantity, seller_user_id
+		FROM order_items
+		WHERE id = ?
+	`, orderItemID).Scan(&orderID, &quantity, &refundedQuantity, &sellerID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		http.Error(w, "Order item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to load order item", http.StatusInternalServerError)
+		return
+	}
+
+	// Locking the order serialises verdicts, refunds and shipping on it.
+	var status string
+	if err := tx.QueryRow("SELECT status FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&status); err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to load order", http.StatusInternalServerError)
+		return
+	}
+	existing, err := authentication.Verdict(tx, orderItemID)
+	if err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to load verdict", http.StatusInternalServerError)
+		return
+	}
+	if existing != "" {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "This item has already been authenticated")
+		return
+	}
+	if !authentication.Inspectable(order.Status(status)) {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "Items on orders in status "+status+" cannot be authenticated")
+		return
+	}
+	if refundedQuantity >= quantity {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "This item has already been refunded")
+		return
+	}
+
+	var refund pendingRefund
+	if verdict == authentication.VerdictFail {
+		if sellerID.Valid && !hasReturnParcel {
+			tx.Rollback()
+			respondWithError(w, http.StatusBadRequest, "A failed item needs a return carrier and tracking number")
+			return
+		}
+
+		var fullyRefunded bool
+		refund, fullyRefunded, err = prepareRefund(tx, orderID, userID, []RefundLinePayload{{OrderItemID: orderItemID, Quantity: quantity - refundedQuantity}}, "Failed authentication", false)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Failed to refund order item %d after failed authentication: %v", orderItemID, err)
+			http.Error(w, "Failed to refund item", http.StatusInternalServerError)
+			return
+		}
+		record.RefundID = refund.ID
+
+		to := order.StatusPartiallyRefunded
+		if fullyRefunded {
+			to = order.StatusRefunded
+		}
+		if _, err := order.Advance(tx, orderID, to, userID, fmt.Sprintf("Refund #%d issued: item failed authentication", refund.ID)); err != nil {
+			tx.Rollback()
+			log.Printf("Failed to move order %d to %s: %v", orderID, to, err)
+			http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+			return
+		}
+		status = string(to)
+
+		if sellerID.Valid {
+			shipment := model.Shipment{
+				OrderID:        orderID,
+				Kind:           shipping.KindReturn,
+				SellerID:       int(sellerID.Int64),
+				Carrier:        returnParcel.Carrier,
+				TrackingNumber: returnParcel.TrackingNumber,
+				OrderItemIDs:   []int{orderItemID},
+			}
+			err := shipping.Create(tx, &shipment, userID, "Returned to seller: failed authentication")
+			if err == shipping.ErrDuplicate {
+				tx.Rollback()
+				respondWithError(w, http.StatusConflict, "This tracking number has already been used")
+				return
+			}
+			if err != nil {
+				tx.Rollback()
+				log.Printf("Failed to create return shipment for order item %d: %v", orderItemID, err)
+				http.Error(w, "Failed to record return shipment", http.StatusInternalServerError)
+				return
+			}
+			record.ReturnShipmentID = shipment.ID
+		}
+	}
+
+	// Store stock never ships inbound, so an order with nothing on its way
+	// from sellers starts authentication with its first verdict.
+	if status == string(order.StatusPaid) {
+		awaiting, err := awaitingInbound(tx, orderID)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Failed to load lines awaiting shipment on order %d: %v", orderID, err)
+			http.Error(w, "Failed to load order items", http.StatusInternalServerError)
+			return
+		}
+		if len(awaiting) == 0 {
+			if _, err := order.Advance(tx, orderID, order.StatusAuthenticating, userID, "Authentication started"); err != nil {
+				tx.Rollback()
+				log.Printf("Failed to move order %d to authenticating: %v", orderID, err)
+				http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+				return
+			}
+			status = string(order.StatusAuthenticating)
+		}
+	}
+
+	if err := authentication.Record(tx, &record); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to record verdict on order item %d: %v", orderItemID, err)
+		http.Error(w, "Failed to record verdict", http.StatusInternalServerError)
+		return
+	}
+
+	if err := settleRefund(tx, h.Payments, &refund); err != nil {
+		tx.Rollback()
+		log.Printf("Payment provider rejected refund for order %d: %v", orderID, err)
+		http.Error(w, "Failed to issue refund", http.StatusBadGateway)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		if refund.ProviderRefundID != "" {
+			log.Printf("Refund %s for order %d was issued but could not be committed", refund.ProviderRefundID, orderID)
+		}
+		http.Error(w, "Failed to record verdict", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("User %d marked order item %d as %s", userID, orderItemID, verdict)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Verdict recorded",
+		"authentication": record,
+		"status":         status,
+	})
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"grailify/internal/currency"
+	"grailify/internal/market"
+	"grailify/internal/model"
+)
+
+const (
+	defaultBidExpiryDays = 30
+	maxBidExpiryDays     = 90
+)
+
+type BidsHandler struct {
+	DB     *sql.DB
+	Market *market.Engine
+}
+
+type BidPayload struct {
+	ItemID            int         `json:"itemId"`
+	Size              string      `json:"size"`
+	Price             model.Money `json:"price"`
+	Currency          string      `json:"currency"`
+	ExpiryDays        int         `json:"expiryDays"`
+	PaymentMethodID   int         `json:"paymentMethodId"`
+	ShippingAddressID int         `json:"shippingAddressId"`
+}
+
+// sizeIDFor resolves a size label to its id. An empty label or "One Size"
+// maps to NULL, matching how item_inventory stores unsized listings.
+func sizeIDFor(db *sql.DB, size string) (sql.NullInt64, error) {
+	if size == "" || size == "One Size" {
+		return sql.NullInt64{}, nil
+	}
+	var sizeID int64
+	if err := db.QueryRow("SELECT id FROM sizes WHERE size_value = ?", size).Scan(&sizeID); err != nil {
+		return sql.NullInt64{}, err
+	}
+	return sql.NullInt64{Int64: sizeID, Valid: true}, nil
+}
+
+func (h *BidsHandler) CreateBid(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload BidPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.ItemID <= 0 || !payload.Price.IsPositive() {
+		respondWithError(w, http.StatusBadRequest, "A bid needs an item and a positive price")
+		return
+	}
+	if payload.ExpiryDays == 0 {
+		payload.ExpiryDays = defaultBidExpiryDays
+	}
+	if payload.ExpiryDays < 1 || payload.ExpiryDays > maxBidExpiryDays {
+		respondWithError(w, http.StatusBadRequest, "Bids can last between 1 and 90 days")
+		return
+	}
+
+	bidCurrency, err := currency.Normalize(payload.Currency)
+	if err != nil {
+		http.Error(w, "Invalid currency", http.StatusBadRequest)
+		return
+	}
+	rates, err := currency.Load(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
+	if !rates.Has(bidCurrency) {
+		http.Error(w, "Unsupported currency", http.StatusBadRequest)
+		return
+	}
+
+	sizeID, err := sizeIDFor(h.DB, payload.Size)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invalid size provided", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error finding size", http.StatusInternalServerError)
+		return
+	}
+
+	var paymentToken sql.NullString
+	err = h.DB.QueryRow("SELECT provider_token FROM user_payment_methods WHERE id = ? AND user_id = ?", payload.PaymentMethodID, userID).Scan(&paymentToken)
+	if err == sql.ErrNoRows || (err == nil && !paymentToken.Valid) {
+		respondWithError(w, http.StatusBadRequest, "Please select a valid payment method.")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load payment method", http.StatusInternalServerError)
+		return
+	}
+
+	// A matched bid becomes an order at once, so it needs an address to work
+	// out shipping and tax.
+	var shippingAddressID int
+	err = h.DB.QueryRow("SELECT id FROM user_addresses WHERE id = ? AND user_id = ?", payload.ShippingAddressID, userID).Scan(&shippingAddressID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Please select a valid shipping address.")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load shipping address", http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, payload.ExpiryDays)
+	result, err := h.DB.Exec(
+		"INSERT INTO bids (user_id, item_id, size_id, price, currency, status, payment_method_id, shipping_address_id, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		userID, payload.ItemID, sizeID, payload.Price, bidCurrency, market.BidOpen, payload.PaymentMethodID, shippingAddressID, expiresAt,
+	)
+	if err != nil {
+		log.Printf("Error creating bid for user %d: %v", userID, err)
+		http.Error(w, "Failed to create bid", http.StatusInternalServerError)
+		return
+	}
+	bidID, _ := result.LastInsertId()
+
+	response := map[string]interface{}{
+		"message":   "Bid placed successfully!",
+		"bidId":     bidID,
+		"status":    market.BidOpen,
+		"currency":  bidCurrency,
+		"expiresAt": expiresAt,
+	}
+
+	fill, err := h.Market.MatchBid(int(bidID))
+	if err != nil {
+		log.Printf("Failed to match bid %d: %v", bidID, err)
+	}
+	if fill != nil {
+		response["message"] = "Your bid was matched and an order has been placed!"
+		response["status"] = market.BidFilled
+		response["orderId"] = fill.OrderID
+		response["price"] = fill.Price
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *BidsHandler) ListBids(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT b.id, b.item_id, i.name, s.size_value, b.price, b.currency, b.status, b.expires_at, b.order_id, b.filled_price, b.created_at
+		FROM bids b
+		JOIN items i ON b.item_id = i.id
+		LEFT JOIN sizes s ON b.size_id = s.id
+		WHERE b.user_id = ?
+		ORDER BY b.created_at DESC, b.id DESC
+	`, userID)
+	if err != nil {
+		http.Error(w, "Failed to query bids", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	bids := []model.Bid{}
+	for rows.Next() {
+		var bid model.Bid
+		var sizeValue sql.NullString
+		var orderID sql.NullInt64
+		var filledPrice model.NullMoney
+		if err := rows.Scan(&bid.ID, &bid.ItemID, &bid.ItemName, &sizeValue, &bid.Price, &bid.Currency, &bid.Status, &bid.ExpiresAt, &orderID, &filledPrice, &bid.CreatedAt); err != nil {
+			http.Error(w, "Failed to scan bid", http.StatusInternalServerError)
+			return
+		}
+		bid.UserID = userID
+		bid.Size = "One Size"
+		if sizeValue.Valid {
+			bid.Size = sizeValue.String
+		}
+		bid.OrderID = int(orderID.Int64)
+		bid.Price.Currency = bid.Currency
+		if filledPrice.Valid {
+			filledPrice.Money.Currency = bid.Currency
+			bid.FilledPrice = &filledPrice.Money
+		}
+		if bid.Status == market.BidOpen && bid.ExpiresAt.Before(now) {
+			bid.Status = market.BidExpired
+		}
+		bids = append(bids, bid)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Row iteration error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bids)
+}
+
+func (h *BidsHandler) CancelBid(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bidID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid bid ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.DB.Exec("UPDATE bids SET status = ? WHERE id = ? AND user_id = ? AND status = ?", market.BidCancelled, bidID, userID, market.BidOpen)
+	if err != nil {
+		log.Printf("Error cancelling bid %d for user %d: %v", bidID, userID, err)
+		http.Error(w, "Failed to cancel bid", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, "Failed to check rows affected", http.StatusInternalServerError)
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, "Open bid not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Bid cancelled"})
+}
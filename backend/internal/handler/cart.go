@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"grailify/internal/cart"
+)
+
+type CartHandler struct {
+	DB *sql.DB
+}
+
+type CartLinePayload struct {
+	InventoryID int `json:"inventoryId"`
+	Quantity    int `json:"quantity"`
+}
+
+// respondWithCart writes the user's cart as it stands after a change.
+func (h *CartHandler) respondWithCart(w http.ResponseWriter, userID, code int) {
+	stored, err := cart.Load(h.DB, userID)
+	if err != nil {
+		log.Printf("Failed to load cart for user %d: %v", userID, err)
+		http.Error(w, "Failed to load cart", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// respondWithCartError maps cart errors to responses.
+func respondWithCartError(w http.ResponseWriter, userID int, err error) {
+	switch err {
+	case cart.ErrListingNotFound:
+		respondWithError(w, http.StatusNotFound, "This listing is no longer available.")
+	case cart.ErrLineNotFound:
+		http.Error(w, "Cart item not found", http.StatusNotFound)
+	case cart.ErrAlreadyInCart:
+		respondWithError(w, http.StatusConflict, "This item is already in your cart.")
+	case cart.ErrInvalidQuantity:
+		respondWithError(w, http.StatusBadRequest, "Quantities must be at least 1.")
+	case cart.ErrNotEnoughStock:
+		respondWithError(w, http.StatusConflict, "There is not enough stock for that quantity.")
+	default:
+		log.Printf("Failed to update cart for user %d: %v", userID, err)
+		http.Error(w, "Failed to update cart", http.StatusInternalServerError)
+	}
+}
+
+func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.respondWithCart(w, userID, http.StatusOK)
+}
+
+func (h *CartHandler) AddToCart(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload CartLinePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.InventoryID <= 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Quantity == 0 {
+		payload.Quantity = 1
+	}
+	if err := cart.Add(h.DB, userID, payload.InventoryID, payload.Quantity); err != nil {
+		respondWithCartError(w, userID, err)
+		return
+	}
+	h.respondWithCart(w, userID, http.StatusCreated)
+}
+
+// UpdateCartItem changes a line's quantity or moves it to another listing,
+// e.g. a different size. Either way the listing's current price is accepted.
+func (h *CartHandler) UpdateCartItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	lineID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid cart item ID", http.StatusBadRequest)
+		return
+	}
+	var payload CartLinePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if payload.InventoryID == 0 || payload.Quantity == 0 {
+		var current sql.NullInt64
+		var quantity int
+		err = h.DB.QueryRow(`
+			SELECT ci.inventory_id, ci.quantity FROM cart_items ci
+			JOIN carts c ON ci.cart_id = c.id
+			WHERE ci.id = ? AND c.user_id = ?
+		`, lineID, userID).Scan(&current, &quantity)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Cart item not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to load cart item", http.StatusInternalServerError)
+			return
+		}
+		if payload.Quantity == 0 {
+			payload.Quantity = quantity
+		}
+		if payload.InventoryID == 0 {
+			if !current.Valid {
+				respondWithCartError(w, userID, cart.ErrListingNotFound)
+				return
+			}
+			payload.InventoryID = int(current.Int64)
+		}
+	}
+
+	if err := cart.Update(h.DB, userID, lineID, payload.InventoryID, payload.Quantity); err != nil {
+		respondWithCartError(w, userID, err)
+		return
+	}
+	h.respondWithCart(w, userID, http.StatusOK)
+}
+
+func (h *CartHandler) RemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	lineID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid cart item ID", http.StatusBadRequest)
+		return
+	}
+	if err := cart.Remove(h.DB, userID, lineID); err != nil {
+		respondWithCartError(w, userID, err)
+		return
+	}
+	h.respondWithCart(w, userID, http.StatusOK)
+}
+
+func (h *CartHandler) ClearCart(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := cart.Clear(h.DB, userID); err != nil {
+		respondWithCartError(w, userID, err)
+		return
+	}
+	h.respondWithCart(w, userID, http.StatusOK)
+}
@@ -0,0 +1,514 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"grailify/internal/cart"
+	"grailify/internal/database"
+	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/payment"
+	"grailify/internal/pricing"
+	"grailify/internal/shipping"
+	"grailify/internal/tax"
+)
+
+// CheckoutPayload places an order. TotalAmount is the total the buyer was
+// quoted, tax and shipping included.
+type CheckoutPayload struct {
+	CartItems         []model.CartItem `json:"cartItems"`
+	TotalAmount       model.Money      `json:"totalAmount"`
+	ShippingAddressID int              `json:"shippingAddressId"`
+	ShippingMethodID  int              `json:"shippingMethodId"`
+	PaymentMethodID   int              `json:"paymentMethodId"`
+}
+
+type QuotePayload struct {
+	CartItems         []model.CartItem `json:"cartItems"`
+	ShippingAddressID int              `json:"shippingAddressId"`
+	ShippingMethodID  int              `json:"shippingMethodId"`
+}
+
+type CartLineChange struct {
+	InventoryID  int          `json:"inventoryId"`
+	ItemID       int          `json:"itemId"`
+	Reason       string       `json:"reason"`
+	ClientPrice  model.Money  `json:"clientPrice"`
+	CurrentPrice *model.Money `json:"currentPrice,omitempty"`
+}
+
+type SoldOutLine struct {
+	InventoryID int    `json:"inventoryId"`
+	ItemID      int    `json:"itemId"`
+	Name        string `json:"name"`
+	Size        string `json:"size"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+type StaleCartResponse struct {
+	Message     string           `json:"message"`
+	Changes     []CartLineChange `json:"changes"`
+	ClientTotal model.Money      `json:"clientTotal"`
+	ServerTotal model.Money      `json:"serverTotal"`
+}
+
+type SoldOutResponse struct {
+	Message string        `json:"message"`
+	SoldOut []SoldOutLine `json:"soldOut"`
+}
+
+type lockedInventory struct {
+	ID         int
+	ItemID     int
+	CategoryID int
+	SellerID   int
+	SizeID     sql.NullInt64
+	Name       string
+	Size       string
+	Price      model.Money
+	Currency   string
+	Stock      int
+}
+
+// lockInventory takes a row lock on every referenced item_inventory row. Rows
+// are locked in ascending id order so concurrent checkouts cannot deadlock.
+// Prices come back rounded with the same policy the item pages display.
+func lockInventory(tx *sql.Tx, cartItems []model.CartItem) (map[int]lockedInventory, error) {
+	rounding, err := pricing.Load(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	seen := make(map[int]bool)
+	for _, cartItem := range cartItems {
+		if !seen[cartItem.InventoryID] {
+			seen[cartItem.InventoryID] = true
+			ids = append(ids, cartItem.InventoryID)
+		}
+	}
+	sort.Ints(ids)
+
+	locked := make(map[int]lockedInventory)
+	for _, id := range ids {
+		var inv lockedInventory
+		var sizeValue sql.NullString
+		var sellerID sql.NullInt64
+		err := tx.QueryRow(`
+			SELECT ii.id, ii.item_id, i.category_id, ii.user_id, ii.size_id, i.name, s.size_value, ii.price, ii.currency, ii.stock
+			FROM item_inventory ii
+			JOIN items i ON ii.item_id = i.id
+			LEFT JOIN sizes s ON ii.size_id = s.id
+			WHERE ii.id = ?
+			FOR UPDATE
+		`, id).Scan(&inv.ID, &inv.ItemID, &inv.CategoryID, &sellerID, &inv.SizeID, &inv.Name, &sizeValue, &inv.Price, &inv.Currency, &inv.Stock)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sizeValue.Valid {
+			inv.Size = sizeValue.String
+		} else {
+			inv.Size = "One Size"
+		}
+		inv.SellerID = int(sellerID.Int64)
+		inv.Price.Currency = inv.Currency
+		inv.Price = rounding.For(inv.CategoryID, inv.Currency).Apply(inv.Price)
+		locked[id] = inv
+	}
+	return locked, nil
+}
+
+// repriceCart checks every cart line against the locked inventory rows and
+// reports any line whose client-side price or item no longer matches.
+func repriceCart(cartItems []model.CartItem, locked map[int]lockedInventory) ([]order.Line, []CartLineChange) {
+	var lines []order.Line
+	var changes []CartLineChange
+
+	for _, cartItem := range cartItems {
+		inv, ok := locked[cartItem.InventoryID]
+		if !ok {
+			changes = append(changes, CartLineChange{InventoryID: cartItem.InventoryID, ItemID: cartItem.ID, Reason: "unavailable", ClientPrice: cartItem.Price})
+			continue
+		}
+
+		if inv.ItemID != cartItem.ID {
+			changes = append(changes, CartLineChange{InventoryID: cartItem.InventoryID, ItemID: cartItem.ID, Reason: "item_mismatch", ClientPrice: cartItem.Price, CurrentPrice: &inv.Price})
+			continue
+		}
+		if cartItem.Currency != "" && cartItem.Currency != inv.Currency {
+			changes = append(changes, CartLineChange{InventoryID: cartItem.InventoryID, ItemID: cartItem.ID, Reason: "currency_changed", ClientPrice: cartItem.Price, CurrentPrice: &inv.Price})
+			continue
+		}
+		if inv.Price.Amount != cartItem.Price.Amount {
+			changes = append(changes, CartLineChange{InventoryID: cartItem.InventoryID, ItemID: cartItem.ID, Reason: "price_changed", ClientPrice: cartItem.Price, CurrentPrice: &inv.Price})
+		}
+		lines = append(lines, order.Line{InventoryID: inv.ID, ItemID: inv.ItemID, CategoryID: inv.CategoryID, SellerID: inv.SellerID, SizeID: inv.SizeID, Price: inv.Price, ListingPrice: inv.Price, Quantity: cartItem.Quantity})
+	}
+
+	return lines, changes
+}
+
+// cartCurrency returns the single currency every locked line is priced in.
+// Orders settle in the listings' currency, so a cart cannot mix currencies.
+func cartCurrency(lines []order.Line, locked map[int]lockedInventory) (string, bool) {
+	var code string
+	for _, line := range lines {
+		c := locked[line.InventoryID].Currency
+		if code != "" && c != code {
+			return "", false
+		}
+		code = c
+	}
+	return code, true
+}
+
+func findSoldOut(lines []order.Line, locked map[int]lockedInventory) []SoldOutLine {
+	requested := make(map[int]int)
+	var ids []int
+	for _, line := range lines {
+		if requested[line.InventoryID] == 0 {
+			ids = append(ids, line.InventoryID)
+		}
+		requested[line.InventoryID] += line.Quantity
+	}
+
+	var soldOut []SoldOutLine
+	for _, id := range ids {
+		inv := locked[id]
+		if requested[id] > inv.Stock {
+			soldOut = append(soldOut, SoldOutLine{
+				InventoryID: inv.ID,
+				ItemID:      inv.ItemID,
+				Name:        inv.Name,
+				Size:        inv.Size,
+				Requested:   requested[id],
+				Available:   max(inv.Stock, 0),
+			})
+		}
+	}
+	return soldOut
+}
+
+func soldOutMessage(soldOut []SoldOutLine) string {
+	names := make([]string, len(soldOut))
+	for i, line := range soldOut {
+		names[i] = fmt.Sprintf("%s (%s)", line.Name, line.Size)
+	}
+	return "Some items in your cart are sold out: " + strings.Join(names, ", ")
+}
+
+func loadShippingAddress(db *sql.DB, userID, addressID int) (model.UserAddress, error) {
+	var a model.UserAddress
+	var line2, region sql.NullString
+	err := db.QueryRow(
+		"SELECT id, user_id, full_name, address_line_1, address_line_2, city, state_province_region, postal_code, country FROM user_addresses WHERE id = ? AND user_id = ?",
+		addressID, userID,
+	).Scan(&a.ID, &a.UserID, &a.FullName, &a.AddressLine1, &line2, &a.City, &region, &a.PostalCode, &a.Country)
+	a.AddressLine2 = line2.String
+	a.StateProvinceRegion = region.String
+	return a, err
+}
+
+// checkoutItems returns the cart to check out: the one sent by the client,
+// or the user's stored cart when none was sent.
+func checkoutItems(db *sql.DB, userID int, sent []model.CartItem) ([]model.CartItem, error) {
+	if len(sent) > 0 {
+		return sent, nil
+	}
+	return cart.CheckoutItems(db, userID)
+}
+
+// verifyCart locks, reprices and quotes the cart for delivery to address,
+// and checks it can be ordered as a single order. When clientTotal is set it
+// must match the quoted total, so buyers are only charged what they were
+// shown. When the cart cannot be ordered, verifyCart writes the response and
+// returns false; the caller must roll back tx.
+func verifyCart(w http.ResponseWriter, tx *sql.Tx, userID int, cartItems []model.CartItem, address model.UserAddress, methodID int, clientTotal *model.Money) ([]order.Line, model.CheckoutQuote, bool) {
+	var quote model.CheckoutQuote
+	for _, cartItem := range cartItems {
+		if cartItem.Quantity < 1 {
+			respondWithError(w, http.StatusBadRequest, "Quantities must be at least 1.")
+			return nil, quote, false
+		}
+	}
+
+	locked, err := lockInventory(tx, cartItems)
+	if err != nil {
+		log.Printf("Failed to lock inventory for user %d: %v", userID, err)
+		http.Error(w, "Failed to verify cart items", http.StatusInternalServerError)
+		return nil, quote, false
+	}
+
+	lines, changes := repriceCart(cartItems, locked)
+	orderCurrency, ok := cartCurrency(lines, locked)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Items priced in different currencies must be ordered separately.")
+		return nil, quote, false
+	}
+
+	if len(lines) > 0 {
+		quote, err = quoteCart(tx, lines, orderCurrency, address, methodID)
+		if err == shipping.ErrNoMethod {
+			respondWithError(w, http.StatusBadRequest, "The selected shipping method does not deliver to this address.")
+			return nil, quote, false
+		}
+		if err != nil {
+			log.Printf("Failed to quote checkout for user %d: %v", userID, err)
+			http.Error(w, "Failed to price order", http.StatusInternalServerError)
+			return nil, quote, false
+		}
+	} else {
+		// Nothing in the cart can still be bought.
+		quote.Total = model.Cents(0, cartItems[0].Currency)
+	}
+
+	if len(changes) > 0 {
+		respondStaleCart(w, "Your cart is out of date. Please review the updated prices.", changes, clientTotal, quote.Total)
+		return nil, quote, false
+	}
+
+	if soldOut := findSoldOut(lines, locked); len(soldOut) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(SoldOutResponse{
+			Message: soldOutMessage(soldOut),
+			SoldOut: soldOut,
+		})
+		return nil, quote, false
+	}
+
+	if clientTotal != nil && clientTotal.Amount != quote.Total.Amount {
+		respondStaleCart(w, "Your order total has changed. Please review it before placing your order.", []CartLineChange{}, clientTotal, quote.Total)
+		return nil, quote, false
+	}
+
+	return lines, quote, true
+}
+
+func respondStaleCart(w http.ResponseWriter, message string, changes []CartLineChange, clientTotal *model.Money, serverTotal model.Money) {
+	response := StaleCartResponse{
+		Message:     message,
+		Changes:     changes,
+		ServerTotal: serverTotal,
+	}
+	if clientTotal != nil {
+		response.ClientTotal = clientTotal.In(serverTotal.Currency)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(response)
+}
+
+// quoteCart prices verified cart lines for delivery to address by the given
+// shipping method, or the cheapest one when methodID is zero.
+func quoteCart(q database.Querier, lines []order.Line, code string, address model.UserAddress, methodID int) (model.CheckoutQuote, error) {
+	var quote model.CheckoutQuote
+	categoryIDs := make([]int, len(lines))
+	for i, line := range lines {
+		categoryIDs[i] = line.CategoryID
+	}
+	method, options, err := shipping.Quote(q, tax.Country(address.Country), categoryIDs, methodID, code)
+	if err != nil {
+		return quote, err
+	}
+	taxes, err := tax.Load(q)
+	if err != nil {
+		return quote, err
+	}
+	quote = model.CheckoutQuote{
+		Subtotal:        order.Total(lines, code),
+		Shipping:        method.Price,
+		Currency:        code,
+		ShippingMethod:  method,
+		ShippingOptions: options,
+	}
+	quote.TaxLines = taxes.Calculate(address, quote.Subtotal, quote.Shipping)
+	quote.Tax = tax.Total(quote.TaxLines, code)
+	quote.Total = quote.Subtotal.Add(quote.Tax).Add(quote.Shipping)
+	return quote, nil
+}
+
+func (h *ProfileHandler) QuoteCheckout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload QuotePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	cartItems, err := checkoutItems(h.DB, userID, payload.CartItems)
+	if err != nil {
+		log.Printf("Failed to load stored cart for user %d: %v", userID, err)
+		http.Error(w, "Failed to load cart", http.StatusInternalServerError)
+		return
+	}
+	if len(cartItems) == 0 {
+		http.Error(w, "Cart is empty", http.StatusBadRequest)
+		return
+	}
+
+	address, err := loadShippingAddress(h.DB, userID, payload.ShippingAddressID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Please select a valid shipping address.")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load shipping address", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	// Nothing is written; the transaction only gives a consistent view of
+	// the cart.
+	defer tx.Rollback()
+
+	_, quote, ok := verifyCart(w, tx, userID, cartItems, address, payload.ShippingMethodID, nil)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
+
+func (h *ProfileHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload CheckoutPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cartItems, err := checkoutItems(h.DB, userID, payload.CartItems)
+	if err != nil {
+		log.Printf("Failed to load stored cart for user %d: %v", userID, err)
+		http.Error(w, "Failed to load cart", http.StatusInternalServerError)
+		return
+	}
+	if len(cartItems) == 0 {
+		http.Error(w, "Cart is empty", http.StatusBadRequest)
+		return
+	}
+
+	var paymentToken sql.NullString
+	err = h.DB.QueryRow("SELECT provider_token FROM user_payment_methods WHERE id = ? AND user_id = ?", payload.PaymentMethodID, userID).Scan(&paymentToken)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Please select a valid payment method.")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load payment method", http.StatusInternalServerError)
+		return
+	}
+	if !paymentToken.Valid {
+		respondWithError(w, http.StatusBadRequest, "This card was saved before online payments were enabled. Please add it again.")
+		return
+	}
+
+	address, err := loadShippingAddress(h.DB, userID, payload.ShippingAddressID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Please select a valid shipping address.")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load shipping address", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	lines, quote, ok := verifyCart(w, tx, userID, cartItems, address, payload.ShippingMethodID, &payload.TotalAmount)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	placed, err := order.Place(tx, h.Payments, order.Placement{
+		BuyerID:           userID,
+		Currency:          quote.Currency,
+		Lines:             lines,
+		Tax:               quote.TaxLines,
+		Shipping:          quote.Shipping,
+		ShippingAddressID: address.ID,
+		ShippingMethodID:  quote.ShippingMethod.MethodID,
+		PaymentMethodID:   payload.PaymentMethodID,
+		PaymentToken:      paymentToken.String,
+		Note:              "Order placed",
+	})
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, payment.ErrDeclined) {
+			respondWithError(w, http.StatusPaymentRequired, "Your card was declined.")
+			return
+		}
+		if errors.Is(err, payment.ErrTimeout) {
+			respondWithError(w, http.StatusGatewayTimeout, "The payment could not be completed. You have not been charged.")
+			return
+		}
+		log.Printf("Failed to place order for user %d: %v", userID, err)
+		http.Error(w, "Failed to place order", http.StatusInternalServerError)
+		return
+	}
+
+	ordered := make([]int, len(lines))
+	for i, line := range lines {
+		ordered[i] = line.InventoryID
+	}
+	if err := cart.RemoveListings(tx, userID, ordered); err != nil {
+		tx.Rollback()
+		if _, refundErr := h.Payments.Refund(placed.AuthorizationID, placed.Total); refundErr != nil {
+			log.Printf("Failed to refund authorization %s for abandoned order %d: %v", placed.AuthorizationID, placed.OrderID, refundErr)
+		}
+		log.Printf("Failed to clear ordered items from cart for user %d: %v", userID, err)
+		http.Error(w, "Failed to finalize order", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		if _, refundErr := h.Payments.Refund(placed.AuthorizationID, placed.Total); refundErr != nil {
+			log.Printf("Failed to refund authorization %s for abandoned order %d: %v", placed.AuthorizationID, placed.OrderID, refundErr)
+		}
+		http.Error(w, "Failed to finalize order", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Order placed successfully!",
+		"orderId":     placed.OrderID,
+		"subtotal":    quote.Subtotal,
+		"tax":         quote.Tax,
+		"shipping":    quote.Shipping,
+		"totalAmount": placed.Total,
+		"currency":    placed.Total.Currency,
+	})
+}
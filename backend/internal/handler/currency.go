@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"grailify/internal/currency"
+	"grailify/internal/model"
+)
+
+type ExchangeRatesHandler struct {
+	DB *sql.DB
+}
+
+type ExchangeRatesPayload struct {
+	// Rates maps a currency code to how many units of it buy one USD.
+	Rates map[string]float64 `json:"rates"`
+}
+
+// displayCurrency reads the ?currency= query parameter, defaulting to the base
+// currency, and loads the rates needed to convert prices into it.
+func displayCurrency(db *sql.DB, r *http.Request) (string, currency.Rates, error) {
+	code, err := currency.Normalize(r.URL.Query().Get("currency"))
+	if err != nil {
+		return "", currency.Rates{}, err
+	}
+	rates, err := currency.Load(db)
+	if err != nil {
+		return "", rates, err
+	}
+	if !rates.Has(code) {
+		return "", rates, currency.ErrUnknownCurrency
+	}
+	return code, rates, nil
+}
+
+// respondWithCurrencyError reports a bad ?currency= value as a client error
+// and anything else as a server error.
+func respondWithCurrencyError(w http.ResponseWriter, err error) {
+	if err == currency.ErrInvalidCode || err == currency.ErrUnknownCurrency {
+		http.Error(w, "Unsupported currency", http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Failed to load exchange rates", http.StatusInternalServerError)
+}
+
+func (h *ExchangeRatesHandler) GetRates(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.DB.Query("SELECT currency, rate, updated_at FROM exchange_rates ORDER BY currency")
+	if err != nil {
+		http.Error(w, "Failed to query exchange rates", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	rates := []model.ExchangeRate{}
+	for rows.Next() {
+		var rate model.ExchangeRate
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.UpdatedAt); err != nil {
+			http.Error(w, "Failed to scan exchange rate", http.StatusInternalServerError)
+			return
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Row iteration error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"base":  currency.Base,
+		"rates": rates,
+	})
+}
+
+// UploadRates replaces the stored rate for every currency in the payload.
+// Currencies not mentioned keep their current rate.
+func (h *ExchangeRatesHandler) UploadRates(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != roleStaff {
+		http.Error(w, "Only staff can upload exchange rates", http.StatusForbidden)
+		return
+	}
+
+	var payload ExchangeRatesPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Rates) == 0 {
+		respondWithError(w, http.StatusBadRequest, "No rates provided")
+		return
+	}
+
+	rates := make(map[string]float64, len(payload.Rates))
+	for raw, rate := range payload.Rates {
+		code, err := currency.Normalize(raw)
+		if err != nil || raw == "" {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("%q is not a currency code", raw))
+			return
+		}
+		if code == currency.Base && rate != 1 {
+			respondWithError(w, http.StatusBadRequest, "The rate for "+currency.Base+" is always 1")
+			return
+		}
+		if rate <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Rate for "+code+" must be positive")
+			return
+		}
+		rates[code] = rate
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	for code, rate := range rates {
+		_, err := tx.Exec(
+			"INSERT INTO exchange_rates (currency, rate, updated_by_user_id) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE rate = VALUES(rate), updated_by_user_id = VALUES(updated_by_user_id)",
+			code, rate, userID,
+		)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Failed to store exchange rate for %s: %v", code, err)
+			http.Error(w, "Failed to store exchange rates", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to store exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("User %d uploaded exchange rates: %v", userID, rates)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Exchange rates updated", "updated": len(rates)})
+}
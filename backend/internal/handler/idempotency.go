@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const idempotencyKeyTTL = 24 * time.Hour
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Idempotent makes a creation handler safe to retry. When the request carries
+// an Idempotency-Key header the first response for that key is stored, and
+// later requests with the same key and payload get the stored response back
+// instead of running the handler again. Reusing a key for a different payload
+// is rejected. Requests without the header are passed through unchanged.
+func Idempotent(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > 255 {
+			respondWithError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		userID, ok := r.Context().Value("userID").(int)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		now := time.Now()
+		if _, err := db.Exec("DELETE FROM idempotency_keys WHERE user_id = ? AND expires_at < ?", userID, now); err != nil {
+			log.Printf("Failed to purge expired idempotency keys for user %d: %v", userID, err)
+		}
+
+		_, err = db.Exec(
+			"INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, expires_at) VALUES (?, ?, ?, ?)",
+			userID, key, requestHash, now.Add(idempotencyKeyTTL),
+		)
+		if err != nil {
+			if !strings.Contains(err.Error(), "Duplicate entry") {
+				log.Printf("Failed to reserve idempotency key for user %d: %v", userID, err)
+				http.Error(w, "Failed to process Idempotency-Key", http.StatusInternalServerError)
+				return
+			}
+			replayIdempotentResponse(db, w, userID, key, requestHash)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			if _, err := db.Exec("DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ?", userID, key); err != nil {
+				log.Printf("Failed to release idempotency key for user %d: %v", userID, err)
+			}
+			return
+		}
+
+		_, err = db.Exec(
+			"UPDATE idempotency_keys SET response_status = ?, response_content_type = ?, response_body = ? WHERE user_id = ? AND idempotency_key = ?",
+			rec.status, rec.Header().Get("Content-Type"), rec.body.Bytes(), userID, key,
+		)
+		if err != nil {
+			log.Printf("Failed to store idempotent response for user %d: %v", userID, err)
+		}
+	}
+}
+
+func replayIdempotentResponse(db *sql.DB, w http.ResponseWriter, userID int, key, requestHash string) {
+	var storedHash string
+	var status sql.NullInt64
+	var contentType sql.NullString
+	var body []byte
+	err := db.QueryRow(
+		"SELECT request_hash, response_status, response_content_type, response_body FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ?",
+		userID, key,
+	).Scan(&storedHash, &status, &contentType, &body)
+	if err != nil {
+		log.Printf("Failed to load idempotency key for user %d: %v", userID, err)
+		http.Error(w, "Failed to process Idempotency-Key", http.StatusInternalServerError)
+		return
+	}
+
+	if storedHash != requestHash {
+		respondWithError(w, http.StatusConflict, "Idempotency-Key has already been used with a different request")
+		return
+	}
+	if !status.Valid {
+		respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	if contentType.String != "" {
+		w.Header().Set("Content-Type", contentType.String)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(status.Int64))
+	w.Write(body)
+}
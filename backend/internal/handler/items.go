@@ -4,21 +4,28 @@ import (
 	"database/sql"
 	"encoding/json"
 	"log"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 	"github.com/gorilla/mux"
+	"grailify/internal/currency"
+	"grailify/internal/fees"
+	"grailify/internal/market"
 	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/pricing"
 )
 
 type ItemsHandler struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Market *market.Engine
+	Stats  *market.Stats
 }
 
 type UpdateListingPayload struct {
-	Price float64 `json:"price"`
-	Stock int     `json:"stock"`
+	Price model.Money `json:"price"`
+	Stock int         `json:"stock"`
 }
 
 type TrendingResponse struct {
@@ -32,12 +39,17 @@ type PaginatedResponse struct {
 	Page       int          `json:"page"`
 }
 
+// InventoryInfo is a listing as shown on the item page. Price and Currency
+// are what checkout charges; DisplayPrice is the same price converted to the
+// currency the page was requested in.
 type InventoryInfo struct {
-	InventoryID int     `json:"inventoryId"`
-	Size        string  `json:"size"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
-	Seller      string  `json:"seller"` 
+	InventoryID  int         `json:"inventoryId"`
+	Size         string      `json:"size"`
+	Price        model.Money `json:"price"`
+	Currency     string      `json:"currency"`
+	DisplayPrice model.Money `json:"displayPrice"`
+	Stock        int         `json:"stock"`
+	Seller       string      `json:"seller"` 
 }
 
 type AllSizeInfo struct {
@@ -47,10 +59,12 @@ type AllSizeInfo struct {
 
 type ItemDetailResponse struct {
 	Item         model.Item           `json:"item"`
-	DisplayPrice float64              `json:"displayPrice"`
+	DisplayPrice model.Money          `json:"displayPrice"`
+	Currency     string               `json:"currency"`
 	PriceHistory []model.PriceHistory `json:"priceHistory"`
 	Inventory    []InventoryInfo      `json:"inventory"`
 	AllSizes     []AllSizeInfo        `json:"allSizes"` 
+	Stats        *model.ItemStats     `json:"stats,omitempty"`
 }
 
 type SearchResult struct {
@@ -70,49 +84,183 @@ type SellPageCategory struct {
 type ListingPayload struct {
     ItemID      int     `json:"itemId"`
     Size        string  `json:"size"`
-    Price       float64 `json:"price"`
-    Stock       int     `json:"stock"`
+    Price       model.Money `json:"price"`
+    Currency    string      `json:"currency"`
+    Stock       int         `json:"stock"`
 }
 
 func (h *ItemsHandler) CreateListing(w http.ResponseWriter, r *http.Request) {
-    userID, ok := r.Context().Value("userID").(int)
-    if !ok {
-        http.Error(w, "Unauthorized: Could not get user ID from token", http.StatusUnauthorized)
-        return
-    }
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized: Could not get user ID from token", http.StatusUnauthorized)
+		return
+	}
 
-    var payload ListingPayload
-    if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-        http.Error(w, "Invalid request body", http.StatusBadRequest)
-        return
-    }
+	var payload ListingPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateListing(payload.Price, payload.Stock); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
-    var sizeID int
-    var sizeIDNull sql.NullInt64
-    if payload.Size != "" && payload.Size != "One Size" { 
-        err := h.DB.QueryRow("SELECT id FROM sizes WHERE size_value = ?", payload.Size).Scan(&sizeID)
-        if err != nil {
-            if err == sql.ErrNoRows {
-                http.Error(w, "Invalid size provided", http.StatusBadRequest)
-                return
-            }
-            http.Error(w, "Database error finding size", http.StatusInternalServerError)
-            return
-        }
-        sizeIDNull = sql.NullInt64{Int64: int64(sizeID), Valid: true}
-    }
+	var sizeID int
+	var sizeIDNull sql.NullInt64
+	if payload.Size != "" && payload.Size != "One Size" {
+		err := h.DB.QueryRow("SELECT id FROM sizes WHERE size_value = ?", payload.Size).Scan(&sizeID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Invalid size provided", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Database error finding size", http.StatusInternalServerError)
+			return
+		}
+		sizeIDNull = sql.NullInt64{Int64: int64(sizeID), Valid: true}
+	}
 
-    query := "INSERT INTO item_inventory (item_id, user_id, size_id, price, stock) VALUES (?, ?, ?, ?, ?)"
-    
-    _, err := h.DB.Exec(query, payload.ItemID, userID, sizeIDNull, payload.Price, payload.Stock)
-    if err != nil {
-        log.Printf("Error creating listing for user %d: %v", userID, err)
-        http.Error(w, "Failed to create listing", http.StatusInternalServerError)
-        return
-    }
+	listingCurrency, err := currency.Normalize(payload.Currency)
+	if err != nil {
+		http.Error(w, "Invalid currency", http.StatusBadRequest)
+		return
+	}
 
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(map[string]string{"message": "Listing created successfully!"})
+	feeBreakdown, err := h.listingTerms(payload.ItemID, payload.Price, listingCurrency)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusBadRequest)
+		return
+	}
+	if err == currency.ErrUnknownCurrency {
+		http.Error(w, "Unsupported currency", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error pricing listing for user %d: %v", userID, err)
+		http.Error(w, "Failed to price listing", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	query := "INSERT INTO item_inventory (item_id, user_id, size_id, price, currency, stock) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, payload.ItemID, userID, sizeIDNull, feeBreakdown.Price, listingCurrency, payload.Stock)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error creating listing for user %d: %v", userID, err)
+		http.Error(w, "Failed to create listing", http.StatusInternalServerError)
+		return
+	}
+	listingID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error reading new listing id for user %d: %v", userID, err)
+		http.Error(w, "Failed to create listing", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to create listing", http.StatusInternalServerError)
+		return
+	}
+
+	fills, err := h.Market.MatchAsk(int(listingID))
+	if err != nil {
+		log.Printf("Error matching listing %d against bids: %v", listingID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Listing created successfully!",
+		"listingId": listingID,
+		"fees":      feeBreakdown,
+		"matches":   fills,
+	})
+}
+
+// validateListing checks the price and stock a seller entered, returning the
+// problem to report or "" if there is none.
+func validateListing(price model.Money, stock int) string {
+	if !price.IsPositive() {
+		return "Price must be greater than zero"
+	}
+	if stock < 0 {
+		return "Stock cannot be negative"
+	}
+	return ""
+}
+
+// listingTerms rounds a seller's asking price with the item's pricing policy
+// and quotes the fees on the rounded price, which is what buyers will pay.
+// Listings can only be priced in currencies we hold an exchange rate for.
+func (h *ItemsHandler) listingTerms(itemID int, price model.Money, code string) (*model.FeeBreakdown, error) {
+	var categoryID int
+	if err := h.DB.QueryRow("SELECT category_id FROM items WHERE id = ?", itemID).Scan(&categoryID); err != nil {
+		return nil, err
+	}
+	rates, err := currency.Load(h.DB)
+	if err != nil {
+		return nil, err
+	}
+	if !rates.Has(code) {
+		return nil, currency.ErrUnknownCurrency
+	}
+	rounding, err := pricing.PolicyFor(h.DB, categoryID, code)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := fees.ScheduleFor(h.DB, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if schedule, err = schedule.In(rates, code); err != nil {
+		return nil, err
+	}
+	breakdown := schedule.Quote(rounding.Apply(price.In(code)))
+	return &breakdown, nil
+}
+
+func (h *ItemsHandler) GetFeePreview(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	itemID, err := strconv.Atoi(params.Get("itemId"))
+	if err != nil || itemID <= 0 {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	price, err := model.ParseMoney(params.Get("price"), "")
+	if err != nil || !price.IsPositive() {
+		http.Error(w, "Invalid price", http.StatusBadRequest)
+		return
+	}
+
+	code, err := currency.Normalize(params.Get("currency"))
+	if err != nil {
+		http.Error(w, "Invalid currency", http.StatusBadRequest)
+		return
+	}
+
+	breakdown, err := h.listingTerms(itemID, price, code)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err == currency.ErrUnknownCurrency {
+		http.Error(w, "Unsupported currency", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to compute fee preview for item %d: %v", itemID, err)
+		http.Error(w, "Failed to compute fees", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(breakdown)
 }
 
 func (h *ItemsHandler) GetSellPageData(w http.ResponseWriter, r *http.Request) {
@@ -146,7 +294,7 @@ func (h *ItemsHandler) GetSellPageData(w http.ResponseWriter, r *http.Request) {
 		var item model.Item
 		var itemID sql.NullInt64
 		var itemName, itemBrand, itemImageURL sql.NullString
-		var itemPrice sql.NullFloat64
+		var itemPrice model.NullMoney
 
 		if err := rows.Scan(&catID, &catName, &catSlug, &itemID, &itemName, &itemBrand, &itemImageURL, &itemPrice); err != nil {
 			log.Printf("Error scanning sell page data: %v", err)
@@ -168,7 +316,7 @@ func (h *ItemsHandler) GetSellPageData(w http.ResponseWriter, r *http.Request) {
 			item.Name = itemName.String
 			item.Brand = itemBrand.String
 			item.ImageURL = itemImageURL.String
-			item.Price = itemPrice.Float64
+			item.Price = itemPrice.Money.In(currency.Base)
 			categoryMap[catID].Items = append(categoryMap[catID].Items, item)
 		}
 	}
@@ -178,7 +326,17 @@ func (h *ItemsHandler) GetSellPageData(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ItemsHandler) GetTrendingItems(w http.ResponseWriter, r *http.Request) {
-	
+	code, rates, err := displayCurrency(h.DB, r)
+	if err != nil {
+		respondWithCurrencyError(w, err)
+		return
+	}
+	rounding, err := pricing.Load(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to load pricing rules", http.StatusInternalServerError)
+		return
+	}
+
 	fetchItems := func(query string) ([]model.Item, error) {
 		rows, err := h.DB.Query(query)
 		if err != nil {
@@ -189,19 +347,22 @@ func (h *ItemsHandler) GetTrendingItems(w http.ResponseWriter, r *http.Request)
 		var items []model.Item
 		for rows.Next() {
 			var item model.Item
-			var displayPrice float64
-			if err := rows.Scan(&item.ID, &item.Name, &item.Brand, &item.ImageURL, &displayPrice); err != nil {
+			var displayPrice model.Money
+			if err := rows.Scan(&item.ID, &item.Name, &item.Brand, &item.ImageURL, &item.CategoryID, &displayPrice); err != nil {
 				log.Printf("Error scanning trending item: %v", err)
 				continue
 			}
-			item.Price = math.Ceil(displayPrice/10.0) * 10.0
+			if item.Price, err = displayAmount(rates, rounding, item.CategoryID, displayPrice, code); err != nil {
+				return nil, err
+			}
+			item.Currency = code
 			items = append(items, item)
 		}
 		return items, rows.Err()
 	}
 
 	sneakersQuery := `
-        SELECT id, name, brand, image_url,
+        SELECT id, name, brand, image_url, category_id,
         COALESCE(
             (SELECT price FROM price_history WHERE item_id = items.id AND type = 'sale' ORDER BY recorded_at DESC LIMIT 1),
             price
@@ -218,7 +379,7 @@ func (h *ItemsHandler) GetTrendingItems(w http.ResponseWriter, r *http.Request)
 	}
 
 	apparelQuery := `
-        SELECT id, name, brand, image_url,
+        SELECT id, name, brand, image_url, category_id,
         COALESCE(
             (SELECT price FROM price_history WHERE item_id = items.id AND type = 'sale' ORDER BY recorded_at DESC LIMIT 1),
             price
@@ -243,12 +404,52 @@ func (h *ItemsHandler) GetTrendingItems(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+const (
+	maxManualSalesPerHour        = 60
+	maxManualSalesPerItemPerHour = 10
+)
+
+// manualSalesLastHour counts manual sales entered in the last hour, either by
+// one recorder or against one item. The window is computed by the database so
+// it agrees with the recorded_at timestamps whatever the app server's clock.
+func manualSalesLastHour(tx *sql.Tx, column string, id int) (int, error) {
+	var count int
+	err := tx.QueryRow(
+		"SELECT COUNT(*) FROM price_history WHERE "+column+" = ? AND type = 'sale' AND source = ? AND recorded_at >= NOW() - INTERVAL 1 HOUR",
+		id, order.SaleSourceManual,
+	).Scan(&count)
+	return count, err
+}
+
+// RecordSale records a sale that did not go through checkout, such as an
+// in-store purchase. Checkout and bid matches record their own sales, so
+// this is limited to staff and service accounts and capped per hour to keep
+// it from being used to push items up the trending lists.
 func (h *ItemsHandler) RecordSale(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != roleStaff && role != roleService {
+		log.Printf("Rejected sale recording by user %d with role %q", userID, role)
+		http.Error(w, "Only staff and service accounts can record sales", http.StatusForbidden)
+		return
+	}
+
 	var requestBody struct {
-		ItemID int `json:"itemId"`
+		ItemID int         `json:"itemId"`
+		Price  model.Money `json:"price"`
+		Size   string      `json:"size"`
 	}
 
-	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	err = json.NewDecoder(r.Body).Decode(&requestBody)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
@@ -258,28 +459,85 @@ func (h *ItemsHandler) RecordSale(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid item ID", http.StatusBadRequest)
 		return
 	}
+	if !requestBody.Price.IsPositive() {
+		http.Error(w, "A sale needs a positive price", http.StatusBadRequest)
+		return
+	}
 
-	query := "UPDATE items SET items_sold = items_sold + 1 WHERE id = ?"
-
-	result, err := h.DB.Exec(query, requestBody.ItemID)
+	sizeID, err := sizeIDFor(h.DB, requestBody.Size)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invalid size provided", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Database update failed", http.StatusInternalServerError)
-		log.Printf("Failed to execute RecordSale update: %v", err)
+		http.Error(w, "Database error finding size", http.StatusInternalServerError)
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	tx, err := h.DB.Begin()
 	if err != nil {
-		http.Error(w, "Failed to get rows affected", http.StatusInternalServerError)
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 		return
 	}
 
-	if rowsAffected == 0 {
+	// Locking the item row serialises concurrent recordings so the caps hold.
+	var lockedItemID int
+	err = tx.QueryRow("SELECT id FROM items WHERE id = ? FOR UPDATE", requestBody.ItemID).Scan(&lockedItemID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
 		http.Error(w, "Item not found, no sale recorded", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		tx.Rollback()
+		http.Error(w, "Database error finding item", http.StatusInternalServerError)
+		return
+	}
 
-	log.Printf("Recorded sale for item ID: %d", requestBody.ItemID)
+	limits := []struct {
+		column string
+		id     int
+		max    int
+		msg    string
+	}{
+		{"recorded_by_user_id", userID, maxManualSalesPerHour, "Too many sales recorded in the last hour"},
+		{"item_id", requestBody.ItemID, maxManualSalesPerItemPerHour, "Too many sales recorded for this item in the last hour"},
+	}
+	for _, limit := range limits {
+		count, err := manualSalesLastHour(tx, limit.column, limit.id)
+		if err != nil {
+			tx.Rollback()
+			http.Error(w, "Failed to check recent sales", http.StatusInternalServerError)
+			return
+		}
+		if count >= limit.max {
+			tx.Rollback()
+			log.Printf("User %d hit the hourly manual sale limit on %s for item %d", userID, limit.column, requestBody.ItemID)
+			respondWithError(w, http.StatusTooManyRequests, limit.msg)
+			return
+		}
+	}
+
+	sale := order.Sale{
+		ItemID:     requestBody.ItemID,
+		SizeID:     sizeID,
+		Price:      requestBody.Price.In(currency.Base),
+		Source:     order.SaleSourceManual,
+		RecordedBy: userID,
+	}
+	if err := order.RecordSale(tx, sale); err != nil {
+		tx.Rollback()
+		http.Error(w, "Database update failed", http.StatusInternalServerError)
+		log.Printf("Failed to record sale for item %d: %v", requestBody.ItemID, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to record sale", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("User %d (%s) recorded a manual sale of item %d (size %q) at %s", userID, role, requestBody.ItemID, requestBody.Size, sale.Price)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Sale recorded successfully"})
 }
@@ -343,7 +601,7 @@ func (h *ItemsHandler) GetAllItems(w http.ResponseWriter, r *http.Request) {
 	offset := (page - 1) * limit
 
 	dataQuery := `
-        SELECT i.id, i.name, i.description, i.brand, i.image_url, i.created_at,
+        SELECT i.id, i.name, i.description, i.brand, i.image_url, i.category_id, i.created_at,
         COALESCE(
             (SELECT ph.price FROM price_history ph WHERE ph.item_id = i.id AND ph.type = 'sale' ORDER BY ph.recorded_at DESC LIMIT 1),
             i.price
@@ -361,13 +619,24 @@ func (h *ItemsHandler) GetAllItems(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	code, rates, err := displayCurrency(h.DB, r)
+	if err != nil {
+		respondWithCurrencyError(w, err)
+		return
+	}
+	rounding, err := pricing.Load(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to load pricing rules", http.StatusInternalServerError)
+		return
+	}
+
 	var items []model.Item
 	for rows.Next() {
 		var item model.Item
 		var description, imageUrl sql.NullString
 		var createdAt sql.NullTime
 
-		if err := rows.Scan(&item.ID, &item.Name, &description, &item.Brand, &imageUrl, &createdAt, &item.Price); err != nil {
+		if err := rows.Scan(&item.ID, &item.Name, &description, &item.Brand, &imageUrl, &item.CategoryID, &createdAt, &item.Price); err != nil {
 			http.Error(w, "Failed to scan item", http.StatusInternalServerError)
 			return
 		}
@@ -378,7 +647,11 @@ func (h *ItemsHandler) GetAllItems(w http.ResponseWriter, r *http.Request) {
 			item.CreatedAt = createdAt.Time
 		}
 		
-		item.Price = math.Ceil(item.Price/10.0) * 10.0
+		if item.Price, err = displayAmount(rates, rounding, item.CategoryID, item.Price, code); err != nil {
+			http.Error(w, "Failed to convert prices", http.StatusInternalServerError)
+			return
+		}
+		item.Currency = code
 		items = append(items, item)
 	}
     if err = rows.Err(); err != nil {
@@ -451,14 +724,33 @@ func (h *ItemsHandler) GetItemByID(w http.ResponseWriter, r *http.Request) {
 		item.ReleaseDate = releaseDate.Time
 	}
 
-	var lastSalePrice float64
+	var lastSalePrice model.Money
 	priceQuery := "SELECT price FROM price_history WHERE item_id = ? AND type = 'sale' ORDER BY recorded_at DESC LIMIT 1"
 	err = h.DB.QueryRow(priceQuery, itemID).Scan(&lastSalePrice)
 	if err != nil {
 		lastSalePrice = item.Price
 	}
 
-	displayPrice := math.Ceil(lastSalePrice/10.0) * 10.0
+	code, rates, err := displayCurrency(h.DB, r)
+	if err != nil {
+		respondWithCurrencyError(w, err)
+		return
+	}
+	rounding, err := pricing.Load(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to load pricing rules", http.StatusInternalServerError)
+		return
+	}
+	displayPrice, err := displayAmount(rates, rounding, item.CategoryID, lastSalePrice, code)
+	if err != nil {
+		http.Error(w, "Failed to convert prices", http.StatusInternalServerError)
+		return
+	}
+	if item.Price, err = displayAmount(rates, rounding, item.CategoryID, item.Price, code); err != nil {
+		http.Error(w, "Failed to convert prices", http.StatusInternalServerError)
+		return
+	}
+	item.Currency = code
 
 	var inventory []InventoryInfo
 	inventoryQuery := `
@@ -466,6 +758,7 @@ func (h *ItemsHandler) GetItemByID(w http.ResponseWriter, r *http.Request) {
             ii.id, 
             s.size_value, 
             ii.price, 
+            ii.currency,
             ii.stock,
             COALESCE(u.username, 'Grailify Store') AS seller_name
         FROM item_inventory ii
@@ -484,10 +777,21 @@ func (h *ItemsHandler) GetItemByID(w http.ResponseWriter, r *http.Request) {
 	for invRows.Next() {
 		var invItem InventoryInfo
 		var sizeValue sql.NullString;
-		if err := invRows.Scan(&invItem.InventoryID, &sizeValue, &invItem.Price, &invItem.Stock, &invItem.Seller); err != nil {
+		if err := invRows.Scan(&invItem.InventoryID, &sizeValue, &invItem.Price, &invItem.Currency, &invItem.Stock, &invItem.Seller); err != nil {
 			http.Error(w, "Failed to scan inventory row", http.StatusInternalServerError)
 			return
 		}
+		invItem.Price.Currency = invItem.Currency
+		invItem.Price = rounding.For(item.CategoryID, invItem.Currency).Apply(invItem.Price)
+		invItem.DisplayPrice = invItem.Price
+		if invItem.Currency != code {
+			converted, err := rates.Convert(invItem.Price, code)
+			if err != nil {
+				http.Error(w, "Failed to convert prices", http.StatusInternalServerError)
+				return
+			}
+			invItem.DisplayPrice = rounding.For(item.CategoryID, code).Apply(converted)
+		}
 		if sizeValue.Valid {
 			invItem.Size = sizeValue.String
 		} else {
@@ -511,6 +815,11 @@ func (h *ItemsHandler) GetItemByID(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Failed to scan price history row", http.StatusInternalServerError)
 			return
 		}
+		historyPoint.Price.Currency = currency.Base
+		if historyPoint.Price, err = rates.Convert(historyPoint.Price, code); err != nil {
+			http.Error(w, "Failed to convert prices", http.StatusInternalServerError)
+			return
+		}
 		priceHistory = append(priceHistory, historyPoint)
 	}
 
@@ -534,15 +843,64 @@ func (h *ItemsHandler) GetItemByID(w http.ResponseWriter, r *http.Request) {
 	response := ItemDetailResponse{
 		Item:         item,
 		DisplayPrice: displayPrice,
+		Currency:     code,
 		Inventory:    inventory,
 		PriceHistory: priceHistory,
 		AllSizes:     allSizes,
 	}
 
+	if stats, err := h.Stats.For(itemID); err != nil {
+		log.Printf("Could not compute stats for item %d: %v", itemID, err)
+	} else {
+		response.Stats = &stats
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+func (h *ItemsHandler) GetOrderBook(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists int
+	if err := h.DB.QueryRow("SELECT 1 FROM items WHERE id = ?", itemID).Scan(&exists); err != nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	size := r.URL.Query().Get("size")
+	filter, err := sizeFilterFor(h.DB, size)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invalid size provided", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error finding size", http.StatusInternalServerError)
+		return
+	}
+
+	code, _, err := displayCurrency(h.DB, r)
+	if err != nil {
+		respondWithCurrencyError(w, err)
+		return
+	}
+
+	book, err := market.Book(h.DB, itemID, filter, code)
+	if err != nil {
+		log.Printf("Failed to build order book for item %d: %v", itemID, err)
+		http.Error(w, "Failed to load order book", http.StatusInternalServerError)
+		return
+	}
+	book.Size = size
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
 func (h *ItemsHandler) UpdateListing(w http.ResponseWriter, r *http.Request) {
     userID, ok := r.Context().Value("userID").(int)
     if !ok {
@@ -562,28 +920,57 @@ func (h *ItemsHandler) UpdateListing(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Invalid request body", http.StatusBadRequest)
         return
     }
+    if msg := validateListing(payload.Price, payload.Stock); msg != "" {
+        http.Error(w, msg, http.StatusBadRequest)
+        return
+    }
 
-    query := "UPDATE item_inventory SET price = ?, stock = ? WHERE id = ? AND user_id = ?"
-    result, err := h.DB.Exec(query, payload.Price, payload.Stock, listingID, userID)
+    var itemID int
+    var listingCurrency string
+    err = h.DB.QueryRow("SELECT item_id, currency FROM item_inventory WHERE id = ? AND user_id = ?", listingID, userID).Scan(&itemID, &listingCurrency)
+    if err == sql.ErrNoRows {
+        http.Error(w, "Listing not found or you do not have permission to edit it", http.StatusNotFound)
+        return
+    }
     if err != nil {
-        log.Printf("Error updating listing %d for user %d: %v", listingID, userID, err)
-        http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+        http.Error(w, "Failed to load listing", http.StatusInternalServerError)
         return
     }
 
-    rowsAffected, err := result.RowsAffected()
+    feeBreakdown, err := h.listingTerms(itemID, payload.Price, listingCurrency)
+    if err == sql.ErrNoRows {
+        http.Error(w, "Item not found", http.StatusNotFound)
+        return
+    }
+    if err == currency.ErrUnknownCurrency {
+        http.Error(w, "Unsupported currency", http.StatusBadRequest)
+        return
+    }
     if err != nil {
-        http.Error(w, "Failed to check rows affected", http.StatusInternalServerError)
+        log.Printf("Error pricing listing %d: %v", listingID, err)
+        http.Error(w, "Failed to price listing", http.StatusInternalServerError)
         return
     }
 
-    if rowsAffected == 0 {
-        http.Error(w, "Listing not found or you do not have permission to edit it", http.StatusNotFound)
+    query := "UPDATE item_inventory SET price = ?, stock = ? WHERE id = ? AND user_id = ?"
+    if _, err := h.DB.Exec(query, feeBreakdown.Price, payload.Stock, listingID, userID); err != nil {
+        log.Printf("Error updating listing %d for user %d: %v", listingID, userID, err)
+        http.Error(w, "Failed to update listing", http.StatusInternalServerError)
         return
     }
 
+    fills, err := h.Market.MatchAsk(listingID)
+    if err != nil {
+        log.Printf("Error matching listing %d against bids: %v", listingID, err)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]string{"message": "Listing updated successfully"})
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "message": "Listing updated successfully",
+        "fees":    feeBreakdown,
+        "matches": fills,
+    })
 }
 
 func (h *ItemsHandler) DeleteListing(w http.ResponseWriter, r *http.Request) {
@@ -621,4 +1008,140 @@ func (h *ItemsHandler) DeleteListing(w http.ResponseWriter, r *http.Request) {
 
     w.WriteHeader(http.StatusOK)
     json.NewEncoder(w).Encode(map[string]string{"message": "Listing deleted successfully"})
-}
\ No newline at end of file
+}
+// sizeFilterFor turns an optional ?size= value into a market filter; an empty
+// value matches every size.
+func sizeFilterFor(db *sql.DB, size string) (market.SizeFilter, error) {
+	if size == "" {
+		return market.SizeFilter{}, nil
+	}
+	sizeID, err := sizeIDFor(db, size)
+	if err != nil {
+		return market.SizeFilter{}, err
+	}
+	return market.SizeFilter{SizeID: sizeID, Valid: true}, nil
+}
+
+// parseHistoryTime accepts a date or an RFC 3339 timestamp. A bare date used
+// as the end of a window includes the whole day.
+func parseHistoryTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func (h *ItemsHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists int
+	if err := h.DB.QueryRow("SELECT 1 FROM items WHERE id = ?", itemID).Scan(&exists); err != nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	queryValues := r.URL.Query()
+	intervalParam := queryValues.Get("interval")
+	if intervalParam == "" {
+		intervalParam = string(market.IntervalDay)
+	}
+	interval, err := market.ParseInterval(intervalParam)
+	if err != nil {
+		http.Error(w, "Interval must be day, week or month", http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now().UTC()
+	if v := queryValues.Get("to"); v != "" {
+		if to, err = parseHistoryTime(v, true); err != nil {
+			http.Error(w, "Invalid 'to' date", http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.AddDate(-1, 0, 0)
+	if v := queryValues.Get("from"); v != "" {
+		if from, err = parseHistoryTime(v, false); err != nil {
+			http.Error(w, "Invalid 'from' date", http.StatusBadRequest)
+			return
+		}
+	}
+	if !from.Before(to) {
+		http.Error(w, "'from' must be before 'to'", http.StatusBadRequest)
+		return
+	}
+
+	size := queryValues.Get("size")
+	filter, err := sizeFilterFor(h.DB, size)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invalid size provided", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error finding size", http.StatusInternalServerError)
+		return
+	}
+
+	series, err := market.LoadCandles(h.DB, itemID, filter, interval, from, to)
+	if err == market.ErrWindowTooLarge {
+		http.Error(w, "Time window is too large for this interval", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to build price history for item %d: %v", itemID, err)
+		http.Error(w, "Failed to load price history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.PriceHistorySeries{
+		ItemID:   itemID,
+		Size:     size,
+		Interval: string(interval),
+		From:     from,
+		To:       to,
+		Series:   series,
+	})
+}
+
+func (h *ItemsHandler) GetItemStats(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.Stats.For(itemID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to compute stats for item %d: %v", itemID, err)
+		http.Error(w, "Failed to load item stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// displayAmount converts a base-currency price into the display currency and
+// rounds it with the policy for that category and currency.
+func displayAmount(rates currency.Rates, rounding pricing.Rules, categoryID int, price model.Money, code string) (model.Money, error) {
+	converted, err := rates.Convert(price.In(currency.Base), code)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return rounding.For(categoryID, code).Apply(converted), nil
+}
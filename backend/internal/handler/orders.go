@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"grailify/internal/authentication"
+	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/payment"
+	"grailify/internal/shipping"
+)
+
+const (
+	roleStaff = "staff"
+	// roleService is for back-office integrations such as the point of sale.
+	roleService = "service"
+	// roleAuthenticator inspects sold items before they ship to buyers.
+	roleAuthenticator = "authenticator"
+)
+
+type OrdersHandler struct {
+	DB       *sql.DB
+	Payments payment.Provider
+}
+
+type OrderDetailResponse struct {
+	Order           model.Order                   `json:"order"`
+	TaxLines        []model.TaxLine               `json:"taxLines"`
+	Shipments       []model.Shipment              `json:"shipments"`
+	Authentications []model.Authentication        `json:"authentications"`
+	History         []model.OrderStatusTransition `json:"history"`
+	Refunds         []model.Refund                `json:"refunds"`
+}
+
+type OrderListResponse struct {
+	Orders     []model.Order `json:"orders"`
+	TotalPages int           `json:"totalPages"`
+	Page       int           `json:"page"`
+}
+
+type TransitionPayload struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+func userRole(db *sql.DB, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// buyerMayTransition lists the transitions a buyer can make on their own
+// order; everything else is reserved for staff.
+func buyerMayTransition(to order.Status) bool {
+	return to == order.StatusCancelled || to == order.StatusDelivered
+}
+
+const orderColumns = "id, user_id, subtotal_amount, tax_amount, shipping_amount, total_amount, currency, status, shipping_address_id, shipping_method_id, created_at"
+
+// loadOrder fetches an order visible to the given user. Staff can see every
+// order, buyers only their own; anything else is reported as not found.
+func (h *OrdersHandler) loadOrder(orderID, userID int, role string) (model.Order, error) {
+	var o model.Order
+	var shippingAddressID, shippingMethodID sql.NullInt64
+	err := h.DB.QueryRow("SELECT "+orderColumns+" FROM orders WHERE id = ?", orderID).
+		Scan(&o.ID, &o.UserID, &o.SubtotalAmount, &o.TaxAmount, &o.ShippingAmount, &o.TotalAmount, &o.Currency, &o.Status, &shippingAddressID, &shippingMethodID, &o.CreatedAt)
+	o.ShippingAddressID = int(shippingAddressID.Int64)
+	o.ShippingMethodID = int(shippingMethodID.Int64)
+	if err != nil {
+		return o, err
+	}
+	if o.UserID != userID && role != roleStaff {
+		return o, sql.ErrNoRows
+	}
+	return o, nil
+}
+
+// loadOrderItems fetches the line items for a set of orders in one query,
+// joined to the catalogue item and the listing each line was bought from.
+func loadOrderItems(db *sql.DB, orderIDs []int) (map[int][]model.OrderItem, error) {
+	itemsByOrder := make(map[int][]model.OrderItem)
+	if len(orderIDs) == 0 {
+		return itemsByOrder, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(orderIDs)), ",")
+	args := make([]interface{}, len(orderIDs))
+	for i, id := range orderIDs {
+		args[i] = id
+	}
+
+	rows, err := db.Query(`
+		SELECT oi.id, oi.order_id, oi.item_id, oi.inventory_id, oi.quantity, oi.price_at_purchase,
+			i.name, i.image_url, oi.size, oi.seller_user_id, u.username
+		FROM order_items oi
+		JOIN items i ON oi.item_id = i.id
+		LEFT JOIN users u ON oi.seller_user_id = u.id
+		WHERE oi.order_id IN (`+placeholders+`)
+		ORDER BY oi.order_id, oi.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.OrderItem
+		var inventoryID, sellerID sql.NullInt64
+		var imageURL, size, seller sql.NullString
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ItemID, &inventoryID, &item.Quantity, &item.PriceAtPurchase,
+			&item.ItemName, &imageURL, &size, &sellerID, &seller); err != nil {
+			return nil, err
+		}
+		item.LineTotal = item.PriceAtPurchase.Mul(item.Quantity)
+		item.InventoryID = int(inventoryID.Int64)
+		item.ItemImageURL = imageURL.String
+		item.SellerID = int(sellerID.Int64)
+		// Lines without a size snapshot predate it and lost their listing, so
+		// their seller is unknown too.
+		if size.Valid {
+			item.Size = size.String
+			item.Seller = seller.String
+			if !sellerID.Valid {
+				item.Seller = "Grailify Store"
+			}
+		}
+		itemsByOrder[item.OrderID] = append(itemsByOrder[item.OrderID], item)
+	}
+	return itemsByOrder, rows.Err()
+}
+
+func (h *OrdersHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit := 20
+
+	var totalOrders int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ?", userID).Scan(&totalOrders); err != nil {
+		http.Error(w, "Failed to count orders", http.StatusInternalServerError)
+		return
+	}
+	totalPages := (totalOrders + limit - 1) / limit
+	offset := (page - 1) * limit
+
+	rows, err := h.DB.Query(`
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to query orders", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	orders := []model.Order{}
+	var orderIDs []int
+	for rows.Next() {
+		var o model.Order
+		var shippingAddressID, shippingMethodID sql.NullInt64
+		if err := rows.Scan(&o.ID, &o.UserID, &o.SubtotalAmount, &o.TaxAmount, &o.ShippingAmount, &o.TotalAmount, &o.Currency, &o.Status, &shippingAddressID, &shippingMethodID, &o.CreatedAt); err != nil {
+			http.Error(w, "Failed to scan order", http.StatusInternalServerError)
+			return
+		}
+		o.ShippingAddressID = int(shippingAddressID.Int64)
+		o.ShippingMethodID = int(shippingMethodID.Int64)
+		orders = append(orders, o)
+		orderIDs = append(orderIDs, o.ID)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Row iteration error", http.StatusInternalServerError)
+		return
+	}
+
+	itemsByOrder, err := loadOrderItems(h.DB, orderIDs)
+	if err != nil {
+		log.Printf("Failed to load order items for user %d: %v", userID, err)
+		http.Error(w, "Failed to load order items", http.StatusInternalServerError)
+		return
+	}
+	for i := range orders {
+		orders[i].Items = itemsByOrder[orders[i].ID]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OrderListResponse{
+		Orders:     orders,
+		TotalPages: totalPages,
+		Page:       page,
+	})
+}
+
+func (h *OrdersHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	o, err := h.loadOrder(orderID, userID, role)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order", http.StatusInternalServerError)
+		return
+	}
+
+	itemsByOrder, err := loadOrderItems(h.DB, []int{o.ID})
+	if err != nil {
+		log.Printf("Failed to load items for order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order items", http.StatusInternalServerError)
+		return
+	}
+	o.Items = itemsByOrder[o.ID]
+
+	taxLines, err := order.TaxLines(h.DB, orderID, o.Currency)
+	if err != nil {
+		log.Printf("Failed to load tax lines for order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order tax", http.StatusInternalServerError)
+		return
+	}
+
+	shipments, err := shipping.ForOrder(h.DB, orderID)
+	if err != nil {
+		log.Printf("Failed to load shipments for order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order shipments", http.StatusInternalServerError)
+		return
+	}
+
+	authentications, err := authentication.ForOrder(h.DB, orderID)
+	if err != nil {
+		log.Printf("Failed to load authentication for order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order authentication", http.StatusInternalServerError)
+		return
+	}
+
+	history, err := order.History(h.DB, orderID)
+	if err != nil {
+		log.Printf("Failed to load status history for order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order history", http.StatusInternalServerError)
+		return
+	}
+
+	refunds, err := loadRefunds(h.DB, orderID)
+	if err != nil {
+		log.Printf("Failed to load refunds for order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order refunds", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OrderDetailResponse{Order: o, TaxLines: taxLines, Shipments: shipments, Authentications: authentications, History: history, Refunds: refunds})
+}
+
+func (h *OrdersHandler) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	var payload TransitionPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	to, err := order.ParseStatus(payload.Status)
+	if err != nil {
+		http.Error(w, "Unknown order status", http.StatusBadRequest)
+		return
+	}
+	if order.IsRefund(to) {
+		http.Error(w, "Use the refunds endpoint to refund an order", http.StatusBadRequest)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	o, err := h.loadOrder(orderID, userID, role)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order", http.StatusInternalServerError)
+		return
+	}
+
+	if role != roleStaff && !buyerMayTransition(to) {
+		http.Error(w, "You are not allowed to move this order to "+string(to), http.StatusForbidden)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	from, err := order.Advance(tx, o.ID, to, userID, payload.Note)
+	if err == order.ErrInvalidTransition {
+		tx.Rollback()
+		http.Error(w, "Cannot move order from "+string(from)+" to "+string(to), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to transition order %d to %s: %v", o.ID, to, err)
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
+
+	if to == order.StatusCancelled && from == order.StatusPendingPayment {
+		if err := order.RestoreStock(tx, o.ID); err != nil {
+			tx.Rollback()
+			log.Printf("Failed to restore stock for cancelled order %d: %v", o.ID, err)
+			http.Error(w, "Failed to release inventory", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if to == order.StatusCancelled && from != order.StatusPendingPayment {
+		refund, _, err := prepareRefund(tx, o.ID, userID, nil, "Order cancelled", true)
+		if err == nil {
+			err = settleRefund(tx, h.Payments, &refund)
+		}
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Failed to refund cancelled order %d: %v", o.ID, err)
+			http.Error(w, "Failed to refund payment", http.StatusBadGateway)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":    "Order status updated",
+		"fromStatus": string(from),
+		"status":     string(to),
+	})
+}
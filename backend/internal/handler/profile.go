@@ -10,10 +10,12 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 	"grailify/internal/model"
+	"grailify/internal/payment"
 )
 
 type ProfileHandler struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Payments payment.Provider
 }
 
 type ProfileResponse struct {
@@ -24,77 +26,6 @@ type ProfileResponse struct {
 	UserListings   []model.UserListing       `json:"userListings"`
 }
 
-type CheckoutPayload struct {
-	CartItems         []model.CartItem `json:"cartItems"`
-	TotalAmount       float64          `json:"totalAmount"`
-	ShippingAddressID int              `json:"shippingAddressId"`
-	PaymentMethodID   int              `json:"paymentMethodId"`
-}
-
-func (h *ProfileHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value("userID").(int)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	var payload CheckoutPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	tx, err := h.DB.Begin()
-	if err != nil {
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-		return
-	}
-
-	orderResult, err := tx.Exec("INSERT INTO orders (user_id, total_amount, status) VALUES (?, ?, ?)", userID, payload.TotalAmount, "Completed")
-	if err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to create order", http.StatusInternalServerError)
-		return
-	}
-
-	orderID, err := orderResult.LastInsertId()
-	if err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to get order ID", http.StatusInternalServerError)
-		return
-	}
-
-	stmt, err := tx.Prepare("INSERT INTO order_items (order_id, item_id, quantity, price_at_purchase) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to prepare order items statement", http.StatusInternalServerError)
-		return
-	}
-	defer stmt.Close()
-
-	for _, item := range payload.CartItems {
-		_, err := stmt.Exec(orderID, item.ID, 1, item.Price)
-		if err != nil {
-			tx.Rollback()
-			log.Printf("Failed to insert order item %d for order %d: %v", item.ID, orderID, err)
-			http.Error(w, "Failed to record order item", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to finalize order", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Order placed successfully!",
-		"orderId": orderID,
-	})
-}
-
 func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	userID, _ := r.Context().Value("userID").(int)
 	
@@ -127,15 +58,22 @@ func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	var orderHistory []model.Order
-    orderRows, _ := h.DB.Query("SELECT id, total_amount, status, created_at FROM orders WHERE user_id = ? ORDER BY created_at DESC", userID)
+	var orderIDs []int
+    orderRows, _ := h.DB.Query("SELECT id, total_amount, currency, status, created_at FROM orders WHERE user_id = ? ORDER BY created_at DESC", userID)
     if orderRows != nil {
         defer orderRows.Close()
         for orderRows.Next() {
             var order model.Order
-            orderRows.Scan(&order.ID, &order.TotalAmount, &order.Status, &order.CreatedAt)
+            orderRows.Scan(&order.ID, &order.TotalAmount, &order.Currency, &order.Status, &order.CreatedAt)
             orderHistory = append(orderHistory, order)
+            orderIDs = append(orderIDs, order.ID)
         }
     }
+	if itemsByOrder, err := loadOrderItems(h.DB, orderIDs); err == nil {
+		for i := range orderHistory {
+			orderHistory[i].Items = itemsByOrder[orderHistory[i].ID]
+		}
+	}
 
 	var userListings []model.UserListing
 	listingRows, _ := h.DB.Query(`
@@ -200,13 +138,39 @@ func (h *ProfileHandler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
     w.WriteHeader(http.StatusOK)
 }
 
+type PaymentMethodPayload struct {
+	CardNumber  string `json:"cardNumber"`
+	ExpiryMonth string `json:"expiryMonth"`
+	ExpiryYear  string `json:"expiryYear"`
+	CVC         string `json:"cvc"`
+}
+
 func (h *ProfileHandler) AddPaymentMethod(w http.ResponseWriter, r *http.Request) {
-    userID, _ := r.Context().Value("userID").(int)
-    var pm model.UserPaymentMethod
-    json.NewDecoder(r.Body).Decode(&pm)
-    query := "INSERT INTO user_payment_methods (user_id, provider, card_type, last_four_digits, expiry_month, expiry_year) VALUES (?, ?, ?, ?, ?, ?)"
-    h.DB.Exec(query, userID, "Stripe", pm.CardType, pm.LastFourDigits, pm.ExpiryMonth, pm.ExpiryYear)
-    w.WriteHeader(http.StatusCreated)
+	userID, _ := r.Context().Value("userID").(int)
+	var payload PaymentMethodPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.Payments.Tokenize(payment.Card{
+		Number:      payload.CardNumber,
+		ExpiryMonth: payload.ExpiryMonth,
+		ExpiryYear:  payload.ExpiryYear,
+		CVC:         payload.CVC,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Card details are invalid")
+		return
+	}
+
+	query := "INSERT INTO user_payment_methods (user_id, provider, provider_token, card_type, last_four_digits, expiry_month, expiry_year) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	if _, err := h.DB.Exec(query, userID, h.Payments.Name(), token.ID, token.CardType, token.LastFour, token.ExpiryMonth, token.ExpiryYear); err != nil {
+		log.Printf("Failed to save payment method for user %d: %v", userID, err)
+		http.Error(w, "Failed to save payment method", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
 }
 
 func (h *ProfileHandler) DeletePaymentMethod(w http.ResponseWriter, r *http.Request) {
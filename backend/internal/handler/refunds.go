@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"grailify/internal/ledger"
+	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/payment"
+)
+
+type RefundLinePayload struct {
+	OrderItemID int `json:"orderItemId"`
+	Quantity    int `json:"quantity"`
+}
+
+type RefundPayload struct {
+	Items  []RefundLinePayload `json:"items"`
+	Reason string              `json:"reason"`
+}
+
+// refundError is a problem with the refund request itself rather than with
+// the database or payment provider.
+type refundError struct {
+	msg string
+}
+
+func (e *refundError) Error() string {
+	return e.msg
+}
+
+type refundableLine struct {
+	OrderItemID      int
+	InventoryID      sql.NullInt64
+	Quantity         int
+	RefundedQuantity int
+	Price            model.Money
+}
+
+type pendingRefund struct {
+	model.Refund
+	authorizationID string
+}
+
+// prepareRefund returns stock for the requested order lines, or for everything
+// not yet refunded when no lines are given, writes the refund ledger rows and
+// takes the refunded units out of the sales history.
+// Stock is only returned when restock is set; items that failed
+// authentication must not go back on sale.
+// It reports whether the whole order has now been refunded. No money moves
+// until settleRefund is called, so the caller can still move the order to its
+// new status and roll back if that is not allowed.
+func prepareRefund(tx *sql.Tx, orderID, actorID int, requested []RefundLinePayload, reason string, restock bool) (pendingRefund, bool, error) {
+	refund := pendingRefund{Refund: model.Refund{OrderID: orderID, Reason: reason}}
+
+	var orderCurrency string
+	var subtotal, orderTax model.Money
+	err := tx.QueryRow("SELECT currency, subtotal_amount, tax_amount FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&orderCurrency, &subtotal, &orderTax)
+	if err == sql.ErrNoRows {
+		return refund, false, order.ErrNotFound
+	}
+	if err != nil {
+		return refund, false, err
+	}
+
+	rows, err := tx.Query("SELECT id, inventory_id, quantity, refunded_quantity, price_at_purchase FROM order_items WHERE order_id = ? ORDER BY id FOR UPDATE", orderID)
+	if err != nil {
+		return refund, false, err
+	}
+	lines := make(map[int]*refundableLine)
+	var lineIDs []int
+	for rows.Next() {
+		line := &refundableLine{Price: model.Cents(0, orderCurrency)}
+		if err := rows.Scan(&line.OrderItemID, &line.InventoryID, &line.Quantity, &line.RefundedQuantity, &line.Price); err != nil {
+			rows.Close()
+			return refund, false, err
+		}
+		lines[line.OrderItemID] = line
+		lineIDs = append(lineIDs, line.OrderItemID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return refund, false, err
+	}
+
+	if len(requested) == 0 {
+		for _, id := range lineIDs {
+			if remaining := lines[id].Quantity - lines[id].RefundedQuantity; remaining > 0 {
+				requested = append(requested, RefundLinePayload{OrderItemID: id, Quantity: remaining})
+			}
+		}
+	}
+	if len(requested) == 0 {
+		return refund, false, &refundError{"Nothing left to refund on this order"}
+	}
+	refund.Amount = model.Cents(0, orderCurrency)
+
+	for _, req := range requested {
+		line, ok := lines[req.OrderItemID]
+		if !ok {
+			return refund, false, &refundError{fmt.Sprintf("Order item %d does not belong to this order", req.OrderItemID)}
+		}
+		if req.Quantity <= 0 {
+			return refund, false, &refundError{fmt.Sprintf("Refund quantity for order item %d must be positive", req.OrderItemID)}
+		}
+		if req.Quantity > line.Quantity-line.RefundedQuantity {
+			return refund, false, &refundError{fmt.Sprintf("Order item %d only has %d unit(s) left to refund", req.OrderItemID, line.Quantity-line.RefundedQuantity)}
+		}
+
+		// Tax is refunded in proportion to the goods it was charged on.
+		goods := line.Price.Mul(req.Quantity)
+		amount := goods.Add(orderTax.Split(int(goods.Amount), int(subtotal.Amount)))
+		line.RefundedQuantity += req.Quantity
+		refund.Amount = refund.Amount.Add(amount)
+		refund.Items = append(refund.Items, model.RefundItem{OrderItemID: req.OrderItemID, Quantity: req.Quantity, Amount: amount})
+
+		if _, err := tx.Exec("UPDATE order_items SET refunded_quantity = refunded_quantity + ? WHERE id = ?", req.Quantity, req.OrderItemID); err != nil {
+			return refund, false, err
+		}
+		if restock && line.InventoryID.Valid {
+			if _, err := tx.Exec("UPDATE item_inventory SET stock = stock + ? WHERE id = ?", req.Quantity, line.InventoryID.Int64); err != nil {
+				return refund, false, err
+			}
+		}
+		if err := ledger.ReverseSale(tx, req.OrderItemID, req.Quantity, line.Quantity); err != nil {
+			return refund, false, err
+		}
+		if err := order.ReverseSales(tx, req.OrderItemID, req.Quantity); err != nil {
+			return refund, false, err
+		}
+	}
+
+	fullyRefunded := true
+	for _, line := range lines {
+		if line.RefundedQuantity < line.Quantity {
+			fullyRefunded = false
+			break
+		}
+	}
+
+	var paymentID int
+	paid, alreadyRefunded := model.Cents(0, orderCurrency), model.Cents(0, orderCurrency)
+	err = tx.QueryRow(
+		"SELECT id, authorization_id, amount, refunded_amount FROM payments WHERE order_id = ? AND status IN ('captured', 'partially_refunded') FOR UPDATE",
+		orderID,
+	).Scan(&paymentID, &refund.authorizationID, &paid, &alreadyRefunded)
+	hasPayment := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return refund, false, err
+	}
+
+	var paymentRef sql.NullInt64
+	if hasPayment {
+		paymentRef = sql.NullInt64{Int64: int64(paymentID), Valid: true}
+		// The last refund on an order returns whatever is left, so rounding
+		// in the tax shares and shipping are refunded too.
+		if fullyRefunded {
+			remaining := paid.Sub(alreadyRefunded)
+			last := &refund.Items[len(refund.Items)-1]
+			last.Amount = last.Amount.Add(remaining.Sub(refund.Amount))
+			refund.Amount = remaining
+		}
+		if refund.Amount.Amount > paid.Sub(alreadyRefunded).Amount {
+			return refund, false, &refundError{"Refund exceeds the amount captured for this order"}
+		}
+		paymentStatus := "partially_refunded"
+		if fullyRefunded || alreadyRefunded.Add(refund.Amount) == paid {
+			paymentStatus = "refunded"
+		}
+		if _, err := tx.Exec("UPDATE payments SET refunded_amount = refunded_amount + ?, status = ? WHERE id = ?", refund.Amount, paymentStatus, paymentID); err != nil {
+			return refund, false, err
+		}
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO refunds (order_id, payment_id, amount, reason, actor_user_id) VALUES (?, ?, ?, ?, ?)",
+		orderID, paymentRef, refund.Amount, reason, actorID,
+	)
+	if err != nil {
+		return refund, false, err
+	}
+	refundID, err := result.LastInsertId()
+	if err != nil {
+		return refund, false, err
+	}
+	refund.ID = int(refundID)
+
+	for _, item := range refund.Items {
+		if _, err := tx.Exec("INSERT INTO refund_items (refund_id, order_item_id, quantity, amount) VALUES (?, ?, ?, ?)", refund.ID, item.OrderItemID, item.Quantity, item.Amount); err != nil {
+			return refund, false, err
+		}
+	}
+
+	return refund, fullyRefunded, nil
+}
+
+// settleRefund sends a prepared refund to the payment provider. It should be
+// the last step before Commit.
+func settleRefund(tx *sql.Tx, provider payment.Provider, refund *pendingRefund) error {
+	if refund.authorizationID == "" || !refund.Amount.IsPositive() {
+		return nil
+	}
+
+	providerRefundID, err := provider.Refund(refund.authorizationID, refund.Amount)
+	if err != nil {
+		return err
+	}
+	refund.ProviderRefundID = providerRefundID
+	if _, err := tx.Exec("UPDATE refunds SET provider_refund_id = ? WHERE id = ?", providerRefundID, refund.ID); err != nil {
+		log.Printf("Refund %d was issued as %s but could not be recorded: %v", refund.ID, providerRefundID, err)
+	}
+	return nil
+}
+
+func loadRefunds(db *sql.DB, orderID int) ([]model.Refund, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.order_id, o.currency, r.amount, r.reason, r.provider_refund_id, r.created_at, ri.order_item_id, ri.quantity, ri.amount
+		FROM refunds r
+		JOIN orders o ON r.order_id = o.id
+		JOIN refund_items ri ON ri.refund_id = r.id
+		WHERE r.order_id = ?
+		ORDER BY r.created_at ASC, r.id ASC, ri.id ASC
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []model.Refund{}
+	for rows.Next() {
+		var refund model.Refund
+		var item model.RefundItem
+		var reason, providerRefundID sql.NullString
+		var orderCurrency string
+		if err := rows.Scan(&refund.ID, &refund.OrderID, &orderCurrency, &refund.Amount, &reason, &providerRefundID, &refund.CreatedAt, &item.OrderItemID, &item.Quantity, &item.Amount); err != nil {
+			return nil, err
+		}
+		refund.Amount.Currency = orderCurrency
+		item.Amount.Currency = orderCurrency
+		if n := len(refunds); n > 0 && refunds[n-1].ID == refund.ID {
+			refunds[n-1].Items = append(refunds[n-1].Items, item)
+			continue
+		}
+		refund.Reason = reason.String
+		refund.ProviderRefundID = providerRefundID.String
+		refund.Items = []model.RefundItem{item}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+func (h *OrdersHandler) CreateRefund(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != roleStaff {
+		http.Error(w, "Only staff can issue refunds", http.StatusForbidden)
+		return
+	}
+
+	var payload RefundPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	refund, fullyRefunded, err := prepareRefund(tx, orderID, userID, payload.Items, payload.Reason, true)
+	var reqErr *refundError
+	if errors.As(err, &reqErr) {
+		tx.Rollback()
+		respondWithError(w, http.StatusBadRequest, reqErr.msg)
+		return
+	}
+	if err == order.ErrNotFound {
+		tx.Rollback()
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to prepare refund for order %d: %v", orderID, err)
+		http.Error(w, "Failed to issue refund", http.StatusInternalServerError)
+		return
+	}
+
+	to := order.StatusPartiallyRefunded
+	if fullyRefunded {
+		to = order.StatusRefunded
+	}
+	from, err := order.Advance(tx, orderID, to, userID, fmt.Sprintf("Refund #%d issued", refund.ID))
+	if err == order.ErrInvalidTransition {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "Orders in status "+string(from)+" cannot be refunded")
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to move order %d to %s: %v", orderID, to, err)
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
+
+	if err := settleRefund(tx, h.Payments, &refund); err != nil {
+		tx.Rollback()
+		log.Printf("Payment provider rejected refund for order %d: %v", orderID, err)
+		http.Error(w, "Failed to issue refund", http.StatusBadGateway)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Refund %s for order %d was issued but could not be committed: %v", refund.ProviderRefundID, orderID, err)
+		http.Error(w, "Failed to record refund", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Refund issued",
+		"refund":  refund.Refund,
+		"status":  string(to),
+	})
+}
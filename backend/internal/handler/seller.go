@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"grailify/internal/currency"
+	"grailify/internal/ledger"
+	"grailify/internal/model"
+	"grailify/internal/order"
+)
+
+type SellerHandler struct {
+	DB *sql.DB
+}
+
+type LedgerResponse struct {
+	Entries    []model.LedgerEntry `json:"entries"`
+	TotalPages int                 `json:"totalPages"`
+	Page       int                 `json:"page"`
+}
+
+type SalesResponse struct {
+	Sales      []model.SellerSale `json:"sales"`
+	Summary    model.SalesSummary `json:"summary"`
+	TotalPages int                `json:"totalPages"`
+	Page       int                `json:"page"`
+}
+
+type PayoutPayload struct {
+	// Amount is in the base currency.
+	Amount model.Money `json:"amount"`
+}
+
+func (h *SellerHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	balance, err := ledger.Balance(h.DB, userID)
+	if err != nil {
+		log.Printf("Failed to compute balance for seller %d: %v", userID, err)
+		http.Error(w, "Failed to load balance", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balance)
+}
+
+func (h *SellerHandler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit := 50
+
+	entries, total, err := ledger.Entries(h.DB, userID, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Failed to load ledger for seller %d: %v", userID, err)
+		http.Error(w, "Failed to load ledger", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LedgerResponse{
+		Entries:    entries,
+		TotalPages: (total + limit - 1) / limit,
+		Page:       page,
+	})
+}
+
+// GetSales lists what the seller has sold with totals over every matching
+// sale. Filters: from and to (dates or RFC 3339 times), itemId and status.
+func (h *SellerHandler) GetSales(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	var filter ledger.SalesFilter
+	if value := query.Get("from"); value != "" {
+		from, err := parseHistoryTime(value, false)
+		if err != nil {
+			http.Error(w, "Invalid from time", http.StatusBadRequest)
+			return
+		}
+		filter.From = from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := parseHistoryTime(value, true)
+		if err != nil {
+			http.Error(w, "Invalid to time", http.StatusBadRequest)
+			return
+		}
+		filter.To = to
+	}
+	if value := query.Get("itemId"); value != "" {
+		itemID, err := strconv.Atoi(value)
+		if err != nil || itemID <= 0 {
+			http.Error(w, "Invalid item ID", http.StatusBadRequest)
+			return
+		}
+		filter.ItemID = itemID
+	}
+	if value := query.Get("status"); value != "" {
+		status, err := order.ParseStatus(value)
+		if err != nil {
+			http.Error(w, "Invalid order status", http.StatusBadRequest)
+			return
+		}
+		filter.Status = string(status)
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit := 50
+
+	sales, total, err := ledger.Sales(h.DB, userID, filter, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Failed to load sales for seller %d: %v", userID, err)
+		http.Error(w, "Failed to load sales", http.StatusInternalServerError)
+		return
+	}
+	summary, err := ledger.SalesSummary(h.DB, userID, filter)
+	if err != nil {
+		log.Printf("Failed to summarise sales for seller %d: %v", userID, err)
+		http.Error(w, "Failed to load sales", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SalesResponse{
+		Sales:      sales,
+		Summary:    summary,
+		TotalPages: (total + limit - 1) / limit,
+		Page:       page,
+	})
+}
+
+// CreatePayout records money paid out to a seller, debiting it from their
+// balance. Staff only.
+func (h *SellerHandler) CreatePayout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sellerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid seller ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != roleStaff {
+		http.Error(w, "Only staff can record payouts", http.StatusForbidden)
+		return
+	}
+
+	var payload PayoutPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	amount := payload.Amount.In(currency.Base)
+	if !amount.IsPositive() {
+		respondWithError(w, http.StatusBadRequest, "Payout amount must be greater than zero")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	err = ledger.RecordPayout(tx, sellerID, amount)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		http.Error(w, "Seller not found", http.StatusNotFound)
+		return
+	}
+	if err == ledger.ErrOverdrawn {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "The payout is more than the seller is owed")
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to record payout of %s to seller %d: %v", amount, sellerID, err)
+		http.Error(w, "Failed to record payout", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to record payout", http.StatusInternalServerError)
+		return
+	}
+
+	balance, err := ledger.Balance(h.DB, sellerID)
+	if err != nil {
+		log.Printf("Failed to compute balance for seller %d: %v", sellerID, err)
+		http.Error(w, "Payout recorded, but the balance could not be loaded", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("User %d recorded a payout of %s to seller %d", userID, amount, sellerID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(balance)
+}
@@ -0,0 +1,419 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"grailify/internal/authentication"
+	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/shipping"
+)
+
+type ShipmentsHandler struct {
+	DB *sql.DB
+}
+
+type ShipmentPayload struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"trackingNumber"`
+	// OrderItemIDs limits a seller shipment to some of their lines. Empty
+	// means every line still waiting to be sent.
+	OrderItemIDs []int `json:"orderItemIds"`
+}
+
+type ShipmentEventPayload struct {
+	Status      string     `json:"status"`
+	Description string     `json:"description"`
+	Location    string     `json:"location"`
+	OccurredAt  *time.Time `json:"occurredAt"`
+}
+
+func (p *ShipmentPayload) valid() bool {
+	p.Carrier = strings.TrimSpace(p.Carrier)
+	p.TrackingNumber = strings.TrimSpace(p.TrackingNumber)
+	return p.Carrier != "" && p.TrackingNumber != ""
+}
+
+// awaitingInbound returns the order lines sellers still have to send to
+// authentication, mapped to their seller. Store stock is already with us and
+// fully refunded lines will never ship.
+func awaitingInbound(tx *sql.Tx, orderID int) (map[int]int, error) {
+	rows, err := tx.Query(`
+		SELECT oi.id, oi.seller_user_id
+		FROM order_items oi
+		WHERE oi.order_id = ? AND oi.seller_user_id IS NOT NULL AND oi.refunded_quantity < oi.quantity
+			AND NOT EXISTS (
+				SELECT 1 FROM shipment_items si
+				JOIN shipments s ON si.shipment_id = s.id
+				WHERE si.order_item_id = oi.id AND s.kind = ?
+			)
+	`, orderID, shipping.KindInbound)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	awaiting := make(map[int]int)
+	for rows.Next() {
+		var orderItemID, sellerID int
+		if err := rows.Scan(&orderItemID, &sellerID); err != nil {
+			return nil, err
+		}
+		awaiting[orderItemID] = sellerID
+	}
+	return awaiting, rows.Err()
+}
+
+// ShipSale records that a seller has sent their lines of an order to
+// authentication. Once every seller line is on its way the order moves to
+// authenticating.
+func (h *ShipmentsHandler) ShipSale(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	var payload ShipmentPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !payload.valid() {
+		respondWithError(w, http.StatusBadRequest, "A shipment needs a carrier and a tracking number")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		http.Error(w, "Failed to load order", http.StatusInternalServerError)
+		return
+	}
+
+	awaiting, err := awaitingInbound(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to load lines awaiting shipment on order %d: %v", orderID, err)
+		http.Error(w, "Failed to load order items", http.StatusInternalServerError)
+		return
+	}
+	var mine []int
+	for orderItemID, sellerID := range awaiting {
+		if sellerID == userID {
+			mine = append(mine, orderItemID)
+		}
+	}
+	sort.Ints(mine)
+	if len(mine) == 0 {
+		tx.Rollback()
+		http.Error(w, "No items awaiting shipment from you on this order", http.StatusNotFound)
+		return
+	}
+
+	if from := order.Status(status); from != order.StatusPaid && from != order.StatusPartiallyRefunded {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "Orders in status "+status+" cannot be shipped")
+		return
+	}
+
+	lines := mine
+	if len(payload.OrderItemIDs) > 0 {
+		lines = nil
+		seen := make(map[int]bool)
+		for _, id := range payload.OrderItemIDs {
+			if awaiting[id] != userID {
+				tx.Rollback()
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Order item %d is not awaiting shipment from you", id))
+				return
+			}
+			if !seen[id] {
+				seen[id] = true
+				lines = append(lines, id)
+			}
+		}
+	}
+
+	shipment := model.Shipment{
+		OrderID:        orderID,
+		Kind:           shipping.KindInbound,
+		SellerID:       userID,
+		Carrier:        payload.Carrier,
+		TrackingNumber: payload.TrackingNumber,
+		OrderItemIDs:   lines,
+	}
+	err = shipping.Create(tx, &shipment, userID, "Shipped by seller to authentication")
+	if err == shipping.ErrDuplicate {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "This tracking number has already been used")
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to create shipment on order %d for seller %d: %v", orderID, userID, err)
+		http.Error(w, "Failed to record shipment", http.StatusInternalServerError)
+		return
+	}
+
+	if len(lines) == len(awaiting) {
+		if _, err := order.Advance(tx, orderID, order.StatusAuthenticating, userID, "All items shipped to authentication"); err != nil {
+			tx.Rollback()
+			log.Printf("Failed to move order %d to authenticating: %v", orderID, err)
+			http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+			return
+		}
+		status = string(order.StatusAuthenticating)
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to record shipment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Shipment recorded",
+		"shipment": shipment,
+		"status":   status,
+	})
+}
+
+// ShipOrder records the parcel sending an order on to its buyer and marks the
+// order shipped, once every line has passed authentication. Staff only.
+func (h *ShipmentsHandler) ShipOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != roleStaff {
+		http.Error(w, "Only staff can ship orders to buyers", http.StatusForbidden)
+		return
+	}
+
+	var payload ShipmentPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !payload.valid() {
+		respondWithError(w, http.StatusBadRequest, "A shipment needs a carrier and a tracking number")
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	from, err := order.Advance(tx, orderID, order.StatusShipped, userID, "Shipped to buyer")
+	if err == order.ErrNotFound {
+		tx.Rollback()
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err == order.ErrInvalidTransition {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "Orders in status "+string(from)+" cannot be shipped")
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to move order %d to shipped: %v", orderID, err)
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
+
+	pending, err := authentication.Pending(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to check authentication", http.StatusInternalServerError)
+		return
+	}
+	if pending > 0 {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "Every item must pass authentication before the order ships")
+		return
+	}
+
+	rows, err := tx.Query("SELECT id FROM order_items WHERE order_id = ? AND refunded_quantity < quantity ORDER BY id", orderID)
+	if err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to load order items", http.StatusInternalServerError)
+		return
+	}
+	var lines []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			tx.Rollback()
+			http.Error(w, "Failed to load order items", http.StatusInternalServerError)
+			return
+		}
+		lines = append(lines, id)
+	}
+	rows.Close()
+
+	shipment := model.Shipment{
+		OrderID:        orderID,
+		Kind:           shipping.KindOutbound,
+		Carrier:        payload.Carrier,
+		TrackingNumber: payload.TrackingNumber,
+		OrderItemIDs:   lines,
+	}
+	err = shipping.Create(tx, &shipment, userID, "Shipped to buyer")
+	if err == shipping.ErrDuplicate {
+		tx.Rollback()
+		respondWithError(w, http.StatusConflict, "This tracking number has already been used")
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to create outbound shipment on order %d: %v", orderID, err)
+		http.Error(w, "Failed to record shipment", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to record shipment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Order shipped",
+		"shipment": shipment,
+		"status":   string(order.StatusShipped),
+	})
+}
+
+// AddEvent records a carrier tracking update. It is meant for staff and for
+// the carrier integration's service account. Delivery of the parcel to the
+// buyer completes the order.
+func (h *ShipmentsHandler) AddEvent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := userRole(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != roleStaff && role != roleService {
+		http.Error(w, "Only staff and service accounts can update tracking", http.StatusForbidden)
+		return
+	}
+
+	var payload ShipmentEventPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	status, err := shipping.ParseStatus(payload.Status)
+	if err != nil {
+		http.Error(w, "Unknown shipment status", http.StatusBadRequest)
+		return
+	}
+	event := model.ShipmentEvent{
+		Status:      status,
+		Description: strings.TrimSpace(payload.Description),
+		Location:    strings.TrimSpace(payload.Location),
+		OccurredAt:  time.Now().UTC(),
+	}
+	if payload.OccurredAt != nil {
+		event.OccurredAt = payload.OccurredAt.UTC()
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	shipment, err := shipping.Lock(tx, shipmentID)
+	if err == shipping.ErrNotFound {
+		tx.Rollback()
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to load shipment", http.StatusInternalServerError)
+		return
+	}
+
+	if err := shipping.AddEvent(tx, shipment.ID, &event); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to add event to shipment %d: %v", shipment.ID, err)
+		http.Error(w, "Failed to record tracking update", http.StatusInternalServerError)
+		return
+	}
+
+	if shipment.Kind == shipping.KindOutbound && status == shipping.StatusDelivered {
+		from, err := order.Advance(tx, shipment.OrderID, order.StatusDelivered, userID, "Delivered by "+shipment.Carrier)
+		if err == order.ErrInvalidTransition {
+			log.Printf("Shipment %d was delivered but order %d is %s", shipment.ID, shipment.OrderID, from)
+		} else if err != nil {
+			tx.Rollback()
+			log.Printf("Failed to move order %d to delivered: %v", shipment.OrderID, err)
+			http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to record tracking update", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
@@ -0,0 +1,263 @@
+package ledger
+
+import (
+	"database/sql"
+	"errors"
+
+	"grailify/internal/currency"
+	"grailify/internal/model"
+)
+
+// Accounts. Every posting debits (positive amount) or credits (negative
+// amount) one of these, and the postings of a transaction always sum to zero.
+// seller_payable is kept per seller; its credit balance is what we owe them.
+// All amounts are in currency.Base.
+const (
+	AccountPlatformCash    = "platform_cash"
+	AccountPlatformRevenue = "platform_revenue"
+	AccountSellerPayable   = "seller_payable"
+)
+
+const (
+	KindSale              = "sale"
+	KindPlatformFee       = "platform_fee"
+	KindPayout            = "payout"
+	KindSaleReversal      = "sale_reversal"
+	KindPlatformFeeRefund = "platform_fee_refund"
+)
+
+var (
+	ErrUnbalanced = errors.New("ledger: postings do not balance")
+	ErrOverdrawn  = errors.New("ledger: payout exceeds what the seller is owed")
+)
+
+type posting struct {
+	account string
+	userID  int
+	amount  model.Money
+}
+
+type reference struct {
+	orderID     int
+	orderItemID int
+}
+
+func nullInt(v int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: v > 0}
+}
+
+func post(tx *sql.Tx, kind string, ref reference, postings []posting) error {
+	var sum model.Money
+	for _, p := range postings {
+		sum = sum.Add(p.amount)
+	}
+	if !sum.IsZero() {
+		return ErrUnbalanced
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO ledger_transactions (kind, order_id, order_item_id) VALUES (?, ?, ?)",
+		kind, nullInt(ref.orderID), nullInt(ref.orderItemID),
+	)
+	if err != nil {
+		return err
+	}
+	transactionID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range postings {
+		_, err := tx.Exec(
+			"INSERT INTO ledger_entries (transaction_id, account, user_id, amount) VALUES (?, ?, ?, ?)",
+			transactionID, p.account, nullInt(p.userID), p.amount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSale credits the seller with the gross sale price of an order line
+// and debits the platform fee from what they are owed.
+func RecordSale(tx *sql.Tx, sellerID, orderID, orderItemID int, gross, fee model.Money) error {
+	ref := reference{orderID: orderID, orderItemID: orderItemID}
+	err := post(tx, KindSale, ref, []posting{
+		{account: AccountPlatformCash, amount: gross},
+		{account: AccountSellerPayable, userID: sellerID, amount: gross.Neg()},
+	})
+	if err != nil {
+		return err
+	}
+	if !fee.IsPositive() {
+		return nil
+	}
+	return post(tx, KindPlatformFee, ref, []posting{
+		{account: AccountSellerPayable, userID: sellerID, amount: fee},
+		{account: AccountPlatformRevenue, amount: fee.Neg()},
+	})
+}
+
+// ReverseSale undoes the seller's share of quantity out of ofQuantity units
+// of an order line, returning the proportional fee to the seller. Lines that
+// were never credited to a seller (store stock) are ignored.
+func ReverseSale(tx *sql.Tx, orderItemID, quantity, ofQuantity int) error {
+	rows, err := tx.Query(`
+		SELECT lt.kind, lt.order_id, le.user_id, le.amount
+		FROM ledger_transactions lt
+		JOIN ledger_entries le ON le.transaction_id = lt.id
+		WHERE lt.order_item_id = ? AND le.account = ? AND lt.kind IN (?, ?)
+	`, orderItemID, AccountSellerPayable, KindSale, KindPlatformFee)
+	if err != nil {
+		return err
+	}
+
+	var orderID, sellerID int
+	var gross, fee model.Money
+	for rows.Next() {
+		var kind string
+		var amount model.Money
+		if err := rows.Scan(&kind, &orderID, &sellerID, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		if kind == KindSale {
+			gross = gross.Sub(amount)
+		} else {
+			fee = fee.Add(amount)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if sellerID == 0 || ofQuantity <= 0 {
+		return nil
+	}
+
+	ref := reference{orderID: orderID, orderItemID: orderItemID}
+	reversedGross := gross.Split(quantity, ofQuantity)
+	err = post(tx, KindSaleReversal, ref, []posting{
+		{account: AccountSellerPayable, userID: sellerID, amount: reversedGross},
+		{account: AccountPlatformCash, amount: reversedGross.Neg()},
+	})
+	if err != nil {
+		return err
+	}
+
+	reversedFee := fee.Split(quantity, ofQuantity)
+	if !reversedFee.IsPositive() {
+		return nil
+	}
+	return post(tx, KindPlatformFeeRefund, ref, []posting{
+		{account: AccountPlatformRevenue, amount: reversedFee},
+		{account: AccountSellerPayable, userID: sellerID, amount: reversedFee.Neg()},
+	})
+}
+
+// RecordPayout debits money paid out to a seller from what they are owed. It
+// locks the seller's user row so concurrent payouts cannot together pay out
+// more than the balance, and returns ErrOverdrawn if amount exceeds it.
+func RecordPayout(tx *sql.Tx, sellerID int, amount model.Money) error {
+	var id int
+	if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", sellerID).Scan(&id); err != nil {
+		return err
+	}
+	payable := model.Cents(0, currency.Base)
+	err := tx.QueryRow(
+		"SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = ? AND user_id = ?",
+		AccountSellerPayable, sellerID,
+	).Scan(&payable)
+	if err != nil {
+		return err
+	}
+	// The payable account carries a credit balance, so what is owed is its
+	// negation.
+	if amount.Amount > payable.Neg().Amount {
+		return ErrOverdrawn
+	}
+	return post(tx, KindPayout, reference{}, []posting{
+		{account: AccountSellerPayable, userID: sellerID, amount: amount},
+		{account: AccountPlatformCash, amount: amount.Neg()},
+	})
+}
+
+// Balance summarises a seller's payable account, with every figure expressed
+// from the seller's point of view (positive means money owed to them).
+func Balance(db *sql.DB, sellerID int) (model.SellerBalance, error) {
+	zero := model.Cents(0, currency.Base)
+	balance := model.SellerBalance{SellerID: sellerID, Balance: zero, Sales: zero, Fees: zero, Payouts: zero, Reversals: zero}
+	rows, err := db.Query(`
+		SELECT lt.kind, SUM(le.amount)
+		FROM ledger_entries le
+		JOIN ledger_transactions lt ON le.transaction_id = lt.id
+		WHERE le.account = ? AND le.user_id = ?
+		GROUP BY lt.kind
+	`, AccountSellerPayable, sellerID)
+	if err != nil {
+		return balance, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var sum model.Money
+		if err := rows.Scan(&kind, &sum); err != nil {
+			return balance, err
+		}
+		owed := sum.Neg()
+		switch kind {
+		case KindSale:
+			balance.Sales = balance.Sales.Add(owed)
+		case KindPlatformFee:
+			balance.Fees = balance.Fees.Sub(owed)
+		case KindPayout:
+			balance.Payouts = balance.Payouts.Sub(owed)
+		case KindSaleReversal:
+			balance.Reversals = balance.Reversals.Sub(owed)
+		case KindPlatformFeeRefund:
+			balance.Fees = balance.Fees.Sub(owed)
+		}
+		balance.Balance = balance.Balance.Add(owed)
+	}
+	return balance, rows.Err()
+}
+
+// Entries lists a seller's ledger postings, newest first, with amounts signed
+// from the seller's point of view.
+func Entries(db *sql.DB, sellerID, limit, offset int) ([]model.LedgerEntry, int, error) {
+	var total int
+	err := db.QueryRow("SELECT COUNT(*) FROM ledger_entries WHERE account = ? AND user_id = ?", AccountSellerPayable, sellerID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT le.id, lt.id, lt.kind, lt.order_id, lt.order_item_id, le.amount, lt.created_at
+		FROM ledger_entries le
+		JOIN ledger_transactions lt ON le.transaction_id = lt.id
+		WHERE le.account = ? AND le.user_id = ?
+		ORDER BY lt.created_at DESC, le.id DESC
+		LIMIT ? OFFSET ?
+	`, AccountSellerPayable, sellerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []model.LedgerEntry{}
+	for rows.Next() {
+		var entry model.LedgerEntry
+		var orderID, orderItemID sql.NullInt64
+		amount := model.Cents(0, currency.Base)
+		if err := rows.Scan(&entry.ID, &entry.TransactionID, &entry.Kind, &orderID, &orderItemID, &amount, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entry.OrderID = int(orderID.Int64)
+		entry.OrderItemID = int(orderItemID.Int64)
+		entry.Amount = amount.Neg()
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,130 @@
+package ledger
+
+import (
+	"database/sql"
+	"time"
+
+	"grailify/internal/currency"
+	"grailify/internal/model"
+)
+
+// SalesFilter narrows a seller's sales report. Zero values match everything;
+// To is exclusive.
+type SalesFilter struct {
+	From   time.Time
+	To     time.Time
+	ItemID int
+	Status string
+}
+
+// salesFrom selects a seller's order lines with what the ledger credited for
+// each of them, net of reversals.
+const salesFrom = `
+	FROM order_items oi
+	JOIN orders o ON oi.order_id = o.id
+	JOIN items i ON oi.item_id = i.id
+	LEFT JOIN (
+		SELECT lt.order_item_id,
+			SUM(CASE WHEN lt.kind IN (?, ?) THEN -le.amount ELSE 0 END) AS gross,
+			SUM(CASE WHEN lt.kind IN (?, ?) THEN le.amount ELSE 0 END) AS fees
+		FROM ledger_transactions lt
+		JOIN ledger_entries le ON le.transaction_id = lt.id
+		WHERE le.account = ? AND le.user_id = ?
+		GROUP BY lt.order_item_id
+	) l ON l.order_item_id = oi.id
+	WHERE oi.seller_user_id = ?
+`
+
+func (f SalesFilter) where(sellerID int) (string, []interface{}) {
+	query := salesFrom
+	args := []interface{}{KindSale, KindSaleReversal, KindPlatformFee, KindPlatformFeeRefund, AccountSellerPayable, sellerID, sellerID}
+	if !f.From.IsZero() {
+		query += " AND o.created_at >= ?"
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		query += " AND o.created_at < ?"
+		args = append(args, f.To)
+	}
+	if f.ItemID > 0 {
+		query += " AND oi.item_id = ?"
+		args = append(args, f.ItemID)
+	}
+	if f.Status != "" {
+		query += " AND o.status = ?"
+		args = append(args, f.Status)
+	}
+	return query, args
+}
+
+// Sales lists a seller's sales matching f, newest first, and how many there
+// are in total.
+func Sales(db *sql.DB, sellerID int, f SalesFilter, limit, offset int) ([]model.SellerSale, int, error) {
+	from, args := f.where(sellerID)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) "+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT oi.id, oi.order_id, oi.item_id, i.name, i.image_url, oi.inventory_id, oi.size, oi.quantity, oi.refunded_quantity,
+			oi.price_at_purchase, oi.listing_price, o.currency, l.gross, l.fees, o.status, o.created_at
+		`+from+`
+		ORDER BY o.created_at DESC, oi.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	sales := []model.SellerSale{}
+	for rows.Next() {
+		var sale model.SellerSale
+		var imageURL, size sql.NullString
+		var inventoryID sql.NullInt64
+		sale.Gross = model.Cents(0, currency.Base)
+		sale.Fees = model.Cents(0, currency.Base)
+		if err := rows.Scan(&sale.OrderItemID, &sale.OrderID, &sale.ItemID, &sale.ItemName, &imageURL, &inventoryID, &size, &sale.Quantity, &sale.RefundedQuantity,
+			&sale.Price, &sale.ListingPrice, &sale.PriceCurrency, &sale.Gross, &sale.Fees, &sale.Status, &sale.SoldAt); err != nil {
+			return nil, 0, err
+		}
+		sale.ItemImageURL = imageURL.String
+		sale.InventoryID = int(inventoryID.Int64)
+		sale.Size = size.String
+		sale.Price.Currency = sale.PriceCurrency
+		sale.ListingPrice.Currency = sale.PriceCurrency
+		sale.Currency = currency.Base
+		sale.Net = sale.Gross.Sub(sale.Fees)
+		sales = append(sales, sale)
+	}
+	return sales, total, rows.Err()
+}
+
+// SalesSummary totals a seller's sales matching f in the base currency. Each
+// line's listing price is converted at the rate its sale was booked at, taken
+// from the ledger credit, so the two averages are comparable.
+func SalesSummary(db *sql.DB, sellerID int, f SalesFilter) (model.SalesSummary, error) {
+	zero := model.Cents(0, currency.Base)
+	summary := model.SalesSummary{Gross: zero, Fees: zero, Net: zero, AverageSalePrice: zero, AverageListingPrice: zero, Currency: currency.Base}
+
+	from, args := f.where(sellerID)
+	var units sql.NullInt64
+	listed := zero
+	err := db.QueryRow(`
+		SELECT SUM(oi.quantity - oi.refunded_quantity), SUM(l.gross), SUM(l.fees),
+			ROUND(SUM(l.gross * oi.listing_price / NULLIF(oi.price_at_purchase, 0)), 2)
+		`+from, args...).
+		Scan(&units, &summary.Gross, &summary.Fees, &listed)
+	if err != nil {
+		return summary, err
+	}
+	summary.UnitsSold = int(units.Int64)
+	summary.Net = summary.Gross.Sub(summary.Fees)
+	if summary.UnitsSold > 0 {
+		summary.AverageSalePrice = summary.Gross.Split(1, summary.UnitsSold)
+		summary.AverageListingPrice = listed.Split(1, summary.UnitsSold)
+	}
+	return summary, nil
+}
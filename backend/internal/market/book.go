@@ -0,0 +1,117 @@
+package market
+
+import (
+	"database/sql"
+	"time"
+
+	"grailify/internal/model"
+	"grailify/internal/pricing"
+)
+
+// bookDepth caps the number of price levels returned on each side.
+const bookDepth = 50
+
+// SizeFilter narrows the book to one size. The zero value matches every size;
+// a valid filter with a NULL SizeID matches unsized ("One Size") listings.
+type SizeFilter struct {
+	SizeID sql.NullInt64
+	Valid  bool
+}
+
+func (f SizeFilter) clause(column string) (string, []interface{}) {
+	if !f.Valid {
+		return "", nil
+	}
+	return " AND " + column + " <=> ?", []interface{}{f.SizeID}
+}
+
+// Book aggregates the open asks and bids for an item in one currency into
+// price levels. Asks are shown at the rounded price buyers pay, so listings
+// whose prices round alike share a level.
+func Book(db *sql.DB, itemID int, size SizeFilter, currency string) (model.OrderBook, error) {
+	book := model.OrderBook{ItemID: itemID, Currency: currency, Asks: []model.BookLevel{}, Bids: []model.BookLevel{}}
+
+	var categoryID int
+	if err := db.QueryRow("SELECT category_id FROM items WHERE id = ?", itemID).Scan(&categoryID); err != nil {
+		return book, err
+	}
+	rounding, err := pricing.PolicyFor(db, categoryID, currency)
+	if err != nil {
+		return book, err
+	}
+
+	sizeClause, sizeArgs := size.clause("size_id")
+	askArgs := append([]interface{}{itemID, currency}, sizeArgs...)
+	askRows, err := db.Query(`
+		SELECT price, SUM(stock), COUNT(*)
+		FROM item_inventory
+		WHERE item_id = ? AND currency = ? AND stock > 0`+sizeClause+`
+		GROUP BY price
+		ORDER BY price ASC
+	`, askArgs...)
+	if err != nil {
+		return book, err
+	}
+	defer askRows.Close()
+	for askRows.Next() {
+		level := model.BookLevel{Price: model.Cents(0, currency)}
+		if err := askRows.Scan(&level.Price, &level.Quantity, &level.Orders); err != nil {
+			return book, err
+		}
+		// Rounding never reorders prices, so equal levels are adjacent. The
+		// depth applies to the merged levels, so rows are read until the
+		// next level would be one too many.
+		level.Price = rounding.Apply(level.Price)
+		if n := len(book.Asks); n > 0 && book.Asks[n-1].Price == level.Price {
+			book.Asks[n-1].Quantity += level.Quantity
+			book.Asks[n-1].Orders += level.Orders
+			continue
+		}
+		if len(book.Asks) == bookDepth {
+			break
+		}
+		book.Asks = append(book.Asks, level)
+	}
+	if err := askRows.Err(); err != nil {
+		return book, err
+	}
+
+	bidArgs := append([]interface{}{itemID, currency, BidOpen, time.Now()}, sizeArgs...)
+	bidArgs = append(bidArgs, bookDepth)
+	bidRows, err := db.Query(`
+		SELECT price, COUNT(*), COUNT(*)
+		FROM bids
+		WHERE item_id = ? AND currency = ? AND status = ? AND expires_at > ?`+sizeClause+`
+		GROUP BY price
+		ORDER BY price DESC
+		LIMIT ?
+	`, bidArgs...)
+	if err != nil {
+		return book, err
+	}
+	defer bidRows.Close()
+	for bidRows.Next() {
+		level := model.BookLevel{Price: model.Cents(0, currency)}
+		if err := bidRows.Scan(&level.Price, &level.Quantity, &level.Orders); err != nil {
+			return book, err
+		}
+		book.Bids = append(book.Bids, level)
+	}
+	if err := bidRows.Err(); err != nil {
+		return book, err
+	}
+
+	if len(book.Asks) > 0 {
+		lowest := book.Asks[0].Price
+		book.LowestAsk = &lowest
+	}
+	if len(book.Bids) > 0 {
+		highest := book.Bids[0].Price
+		book.HighestBid = &highest
+	}
+	if book.LowestAsk != nil && book.HighestBid != nil {
+		spread := book.LowestAsk.Sub(*book.HighestBid)
+		book.Spread = &spread
+	}
+	return book, nil
+}
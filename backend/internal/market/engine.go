@@ -0,0 +1,340 @@
+package market
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"grailify/internal/model"
+	"grailify/internal/order"
+	"grailify/internal/payment"
+	"grailify/internal/pricing"
+	"grailify/internal/shipping"
+	"grailify/internal/tax"
+)
+
+const (
+	BidOpen          = "open"
+	BidFilled        = "filled"
+	BidCancelled     = "cancelled"
+	BidExpired       = "expired"
+	BidPaymentFailed = "payment_failed"
+)
+
+// maxFillsPerAsk bounds how many bids a single listing update can fill.
+const maxFillsPerAsk = 100
+
+// ErrNoShippingAddress is returned for a bid with nowhere to deliver to.
+var ErrNoShippingAddress = errors.New("market: bid has no shipping address")
+
+// errBidDropped means the bid could not be filled and was taken out of the
+// book, so matching can move on to the next one.
+var errBidDropped = errors.New("market: bid dropped")
+
+// Engine matches buyer bids against seller asks (item_inventory rows) in the
+// same currency. A trade always happens at the price of the order that was
+// already resting in the book, and creates a paid order for the bidder.
+type Engine struct {
+	DB       *sql.DB
+	Payments payment.Provider
+}
+
+type Fill struct {
+	BidID       int         `json:"bidId"`
+	InventoryID int         `json:"inventoryId"`
+	OrderID     int         `json:"orderId"`
+	Price       model.Money `json:"price"`
+}
+
+type restingAsk struct {
+	InventoryID int
+	ItemID      int
+	CategoryID  int
+	SellerID    int
+	SizeID      sql.NullInt64
+	Price       model.Money
+	Currency    string
+	Stock       int
+}
+
+type restingBid struct {
+	ID                int
+	UserID            int
+	ItemID            int
+	SizeID            sql.NullInt64
+	Price             model.Money
+	Currency          string
+	Status            string
+	PaymentMethodID   sql.NullInt64
+	PaymentToken      sql.NullString
+	ShippingAddressID sql.NullInt64
+}
+
+const bidColumns = `b.id, b.user_id, b.item_id, b.size_id, b.price, b.currency, b.status, b.payment_method_id, pm.provider_token, b.shipping_address_id`
+
+func scanBid(row *sql.Row) (restingBid, error) {
+	var b restingBid
+	err := row.Scan(&b.ID, &b.UserID, &b.ItemID, &b.SizeID, &b.Price, &b.Currency, &b.Status, &b.PaymentMethodID, &b.PaymentToken, &b.ShippingAddressID)
+	b.Price.Currency = b.Currency
+	return b, err
+}
+
+// MatchBid fills an open bid against the lowest ask at or below its price.
+// It returns nil when nothing matched.
+func (e *Engine) MatchBid(bidID int) (*Fill, error) {
+	b, err := scanBid(e.DB.QueryRow(`
+		SELECT `+bidColumns+`
+		FROM bids b
+		LEFT JOIN user_payment_methods pm ON b.payment_method_id = pm.id
+		WHERE b.id = ?
+	`, bidID))
+	if err != nil {
+		return nil, err
+	}
+	if b.Status != BidOpen {
+		return nil, nil
+	}
+
+	tx, err := e.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Asks are always locked before bids, matching MatchAsk.
+	var a restingAsk
+	var sellerID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT ii.id, ii.item_id, i.category_id, ii.user_id, ii.size_id, ii.price, ii.currency, ii.stock
+		FROM item_inventory ii
+		JOIN items i ON ii.item_id = i.id
+		WHERE ii.item_id = ? AND ii.size_id <=> ? AND ii.currency = ? AND ii.stock > 0 AND ii.price <= ?
+			AND (ii.user_id IS NULL OR ii.user_id <> ?)
+		ORDER BY ii.price ASC, ii.id ASC
+		LIMIT 1
+		FOR UPDATE
+	`, b.ItemID, b.SizeID, b.Currency, b.Price, b.UserID).Scan(&a.InventoryID, &a.ItemID, &a.CategoryID, &sellerID, &a.SizeID, &a.Price, &a.Currency, &a.Stock)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	a.SellerID = int(sellerID.Int64)
+	a.Price.Currency = a.Currency
+
+	// Asks saved before their category's rounding policy changed may round
+	// up past the bid.
+	rounding, err := pricing.PolicyFor(tx, a.CategoryID, a.Currency)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if a.Price = rounding.Apply(a.Price); a.Price.Amount > b.Price.Amount {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	b, err = scanBid(tx.QueryRow(`
+		SELECT `+bidColumns+`
+		FROM bids b
+		LEFT JOIN user_payment_methods pm ON b.payment_method_id = pm.id
+		WHERE b.id = ? AND b.status = ? AND b.expires_at > ?
+		FOR UPDATE
+	`, bidID, BidOpen, time.Now()))
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	fill, err := e.fill(tx, b, a, a.Price)
+	if err == errBidDropped {
+		return nil, nil
+	}
+	return fill, err
+}
+
+// MatchAsk fills a listing against the highest open bids at or above its
+// price, one unit per bid, for as long as it has stock.
+func (e *Engine) MatchAsk(inventoryID int) ([]Fill, error) {
+	var fills []Fill
+	for len(fills) < maxFillsPerAsk {
+		tx, err := e.DB.Begin()
+		if err != nil {
+			return fills, err
+		}
+
+		var a restingAsk
+		var sellerID sql.NullInt64
+		err = tx.QueryRow(`
+			SELECT ii.id, ii.item_id, i.category_id, ii.user_id, ii.size_id, ii.price, ii.currency, ii.stock
+			FROM item_inventory ii
+			JOIN items i ON ii.item_id = i.id
+			WHERE ii.id = ?
+			FOR UPDATE
+		`, inventoryID).Scan(&a.InventoryID, &a.ItemID, &a.CategoryID, &sellerID, &a.SizeID, &a.Price, &a.Currency, &a.Stock)
+		if err == sql.ErrNoRows || (err == nil && a.Stock <= 0) {
+			tx.Rollback()
+			return fills, nil
+		}
+		if err != nil {
+			tx.Rollback()
+			return fills, err
+		}
+		a.SellerID = int(sellerID.Int64)
+		a.Price.Currency = a.Currency
+
+		rounding, err := pricing.PolicyFor(tx, a.CategoryID, a.Currency)
+		if err != nil {
+			tx.Rollback()
+			return fills, err
+		}
+		a.Price = rounding.Apply(a.Price)
+
+		b, err := scanBid(tx.QueryRow(`
+			SELECT `+bidColumns+`
+			FROM bids b
+			LEFT JOIN user_payment_methods pm ON b.payment_method_id = pm.id
+			WHERE b.item_id = ? AND b.size_id <=> ? AND b.currency = ? AND b.status = ? AND b.expires_at > ? AND b.price >= ?
+				AND b.user_id <> ?
+			ORDER BY b.price DESC, b.created_at ASC, b.id ASC
+			LIMIT 1
+			FOR UPDATE
+		`, a.ItemID, a.SizeID, a.Currency, BidOpen, time.Now(), a.Price, a.SellerID))
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			return fills, nil
+		}
+		if err != nil {
+			tx.Rollback()
+			return fills, err
+		}
+
+		fill, err := e.fill(tx, b, a, b.Price)
+		if err == errBidDropped {
+			continue
+		}
+		if err != nil {
+			return fills, err
+		}
+		fills = append(fills, *fill)
+	}
+	return fills, nil
+}
+
+// fill turns a locked bid and ask into an order for the bidder and commits
+// tx. A bid whose payment fails is taken out of the book so it cannot block
+// later matches.
+func (e *Engine) fill(tx *sql.Tx, b restingBid, a restingAsk, price model.Money) (*Fill, error) {
+	if !b.PaymentToken.Valid {
+		tx.Rollback()
+		e.dropBid(b.ID, BidPaymentFailed)
+		return nil, errBidDropped
+	}
+
+	taxLines, method, err := bidDelivery(tx, b, a, price)
+	if err == ErrNoShippingAddress || err == shipping.ErrNoMethod {
+		// Bids from before addresses were required, or whose address
+		// we cannot ship to, can never be filled.
+		tx.Rollback()
+		e.dropBid(b.ID, BidCancelled)
+		return nil, errBidDropped
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	placed, err := order.Place(tx, e.Payments, order.Placement{
+		BuyerID:           b.UserID,
+		Currency:          a.Currency,
+		Tax:               taxLines,
+		Shipping:          method.Price,
+		ShippingAddressID: int(b.ShippingAddressID.Int64),
+		ShippingMethodID:  method.MethodID,
+		Lines: []order.Line{{
+			InventoryID:  a.InventoryID,
+			ItemID:       a.ItemID,
+			CategoryID:   a.CategoryID,
+			SellerID:     a.SellerID,
+			SizeID:       a.SizeID,
+			Price:        price,
+			ListingPrice: a.Price,
+			Quantity:     1,
+		}},
+		PaymentMethodID: int(b.PaymentMethodID.Int64),
+		PaymentToken:    b.PaymentToken.String,
+		Note:            fmt.Sprintf("Bid #%d matched", b.ID),
+	})
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, payment.ErrDeclined) || errors.Is(err, payment.ErrInvalidToken) {
+			e.dropBid(b.ID, BidPaymentFailed)
+			return nil, errBidDropped
+		}
+		return nil, err
+	}
+
+	refund := func() {
+		if _, err := e.Payments.Refund(placed.AuthorizationID, placed.Total); err != nil {
+			log.Printf("Failed to refund authorization %s for abandoned bid %d: %v", placed.AuthorizationID, b.ID, err)
+		}
+	}
+
+	_, err = tx.Exec("UPDATE bids SET status = ?, order_id = ?, filled_price = ?, filled_at = ? WHERE id = ?", BidFilled, placed.OrderID, price, time.Now(), b.ID)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		refund()
+		return nil, err
+	}
+
+	log.Printf("Matched bid %d with listing %d at %s %s (order %d)", b.ID, a.InventoryID, price, price.Currency, placed.OrderID)
+	return &Fill{BidID: b.ID, InventoryID: a.InventoryID, OrderID: placed.OrderID, Price: price}, nil
+}
+
+// bidDelivery prices delivery of a filled bid to the address the bidder gave,
+// by the cheapest shipping method, and the tax owed on top of the bid. It
+// returns ErrNoShippingAddress when the bid has no address or it has been
+// deleted, and shipping.ErrNoMethod when nothing ships there.
+func bidDelivery(tx *sql.Tx, b restingBid, a restingAsk, price model.Money) ([]model.TaxLine, model.ShippingOption, error) {
+	var method model.ShippingOption
+	if !b.ShippingAddressID.Valid {
+		return nil, method, ErrNoShippingAddress
+	}
+	var address model.UserAddress
+	err := tx.QueryRow("SELECT id, country, state_province_region FROM user_addresses WHERE id = ?", b.ShippingAddressID.Int64).
+		Scan(&address.ID, &address.Country, &address.StateProvinceRegion)
+	if err == sql.ErrNoRows {
+		return nil, method, ErrNoShippingAddress
+	}
+	if err != nil {
+		return nil, method, err
+	}
+
+	method, _, err = shipping.Quote(tx, tax.Country(address.Country), []int{a.CategoryID}, 0, price.Currency)
+	if err != nil {
+		return nil, method, err
+	}
+	taxes, err := tax.Load(tx)
+	if err != nil {
+		return nil, method, err
+	}
+	return taxes.Calculate(address, price, method.Price), method, nil
+}
+
+func (e *Engine) dropBid(bidID int, status string) {
+	if _, err := e.DB.Exec("UPDATE bids SET status = ? WHERE id = ? AND status = ?", status, bidID, BidOpen); err != nil {
+		log.Printf("Failed to mark bid %d as %s: %v", bidID, status, err)
+	}
+}
@@ -0,0 +1,138 @@
+package market
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"grailify/internal/currency"
+	"grailify/internal/model"
+)
+
+type Interval string
+
+const (
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// maxCandles bounds how many buckets a single history request may span.
+const maxCandles = 1000
+
+var (
+	ErrInvalidInterval = errors.New("market: invalid interval")
+	ErrWindowTooLarge  = errors.New("market: time window too large for interval")
+)
+
+func ParseInterval(s string) (Interval, error) {
+	switch Interval(s) {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+		return Interval(s), nil
+	}
+	return "", ErrInvalidInterval
+}
+
+// bucketStart returns the start of the bucket containing t, in UTC. Weeks
+// start on Monday.
+func (i Interval) bucketStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch i {
+	case IntervalWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func (i Interval) next(start time.Time) time.Time {
+	switch i {
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// PricePoint is one price_history row.
+type PricePoint struct {
+	Kind       string
+	Price      model.Money
+	RecordedAt time.Time
+}
+
+// LoadCandles buckets an item's price_history rows in [from, to) into OHLC
+// candles in the base currency, one series per row type.
+func LoadCandles(db *sql.DB, itemID int, size SizeFilter, interval Interval, from, to time.Time) (map[string][]model.PriceCandle, error) {
+	buckets := 0
+	for start := interval.bucketStart(from); start.Before(to); start = interval.next(start) {
+		if buckets++; buckets > maxCandles {
+			return nil, ErrWindowTooLarge
+		}
+	}
+
+	sizeClause, sizeArgs := size.clause("size_id")
+	args := append([]interface{}{itemID, from, to}, sizeArgs...)
+	rows, err := db.Query(`
+		SELECT type, price, recorded_at
+		FROM price_history
+		WHERE item_id = ? AND recorded_at >= ? AND recorded_at < ?`+sizeClause+`
+		ORDER BY type, recorded_at, id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []PricePoint
+	for rows.Next() {
+		p := PricePoint{Price: model.Cents(0, currency.Base)}
+		if err := rows.Scan(&p.Kind, &p.Price, &p.RecordedAt); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return Candles(points, interval), nil
+}
+
+// Candles buckets points, ordered by kind and then time, into OHLC candles,
+// one series per kind. Buckets with no points are left out.
+func Candles(points []PricePoint, interval Interval) map[string][]model.PriceCandle {
+	series := make(map[string][]model.PriceCandle)
+	sums := make(map[string]model.Money)
+	for _, p := range points {
+		start := interval.bucketStart(p.RecordedAt)
+		candles := series[p.Kind]
+		if n := len(candles); n > 0 && candles[n-1].Start.Equal(start) {
+			c := &candles[n-1]
+			if p.Price.Amount > c.High.Amount {
+				c.High = p.Price
+			}
+			if p.Price.Amount < c.Low.Amount {
+				c.Low = p.Price
+			}
+			c.Close = p.Price
+			c.Volume++
+			sums[p.Kind] = sums[p.Kind].Add(p.Price)
+			c.Average = sums[p.Kind].Split(1, c.Volume)
+			continue
+		}
+		sums[p.Kind] = p.Price
+		series[p.Kind] = append(candles, model.PriceCandle{
+			Start:   start,
+			Open:    p.Price,
+			High:    p.Price,
+			Low:     p.Price,
+			Close:   p.Price,
+			Volume:  1,
+			Average: p.Price,
+		})
+	}
+	return series
+}
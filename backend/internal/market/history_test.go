@@ -0,0 +1,105 @@
+package market
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"grailify/internal/model"
+)
+
+func TestCandles(t *testing.T) {
+	usd := func(cents int64) model.Money { return model.Cents(cents, "USD") }
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	sale := func(when time.Time, cents int64) PricePoint {
+		return PricePoint{Kind: "sale", Price: usd(cents), RecordedAt: when}
+	}
+
+	tests := []struct {
+		name     string
+		interval Interval
+		points   []PricePoint
+		want     map[string][]model.PriceCandle
+	}{
+		{
+			name:     "no points",
+			interval: IntervalDay,
+			want:     map[string][]model.PriceCandle{},
+		},
+		{
+			name:     "daily buckets",
+			interval: IntervalDay,
+			points: []PricePoint{
+				sale(at(time.March, 2, 10), 10000),
+				sale(at(time.March, 2, 15), 12000),
+				sale(at(time.March, 2, 18), 9000),
+				sale(at(time.March, 3, 9), 11000),
+			},
+			want: map[string][]model.PriceCandle{
+				"sale": {
+					{Start: at(time.March, 2, 0), Open: usd(10000), High: usd(12000), Low: usd(9000), Close: usd(9000), Volume: 3, Average: usd(10333)},
+					{Start: at(time.March, 3, 0), Open: usd(11000), High: usd(11000), Low: usd(11000), Close: usd(11000), Volume: 1, Average: usd(11000)},
+				},
+			},
+		},
+		{
+			name:     "weeks start on Monday",
+			interval: IntervalWeek,
+			points: []PricePoint{
+				sale(at(time.March, 1, 12), 10000),
+				sale(at(time.March, 2, 12), 10100),
+				sale(at(time.March, 8, 23), 10300),
+				sale(at(time.March, 9, 0), 10400),
+			},
+			want: map[string][]model.PriceCandle{
+				"sale": {
+					{Start: at(time.February, 23, 0), Open: usd(10000), High: usd(10000), Low: usd(10000), Close: usd(10000), Volume: 1, Average: usd(10000)},
+					{Start: at(time.March, 2, 0), Open: usd(10100), High: usd(10300), Low: usd(10100), Close: usd(10300), Volume: 2, Average: usd(10200)},
+					{Start: at(time.March, 9, 0), Open: usd(10400), High: usd(10400), Low: usd(10400), Close: usd(10400), Volume: 1, Average: usd(10400)},
+				},
+			},
+		},
+		{
+			name:     "months are bucketed in UTC",
+			interval: IntervalMonth,
+			points: []PricePoint{
+				sale(at(time.March, 31, 22), 10000),
+				sale(time.Date(2026, time.April, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), 9500),
+				sale(at(time.April, 1, 0), 9800),
+			},
+			want: map[string][]model.PriceCandle{
+				"sale": {
+					{Start: at(time.March, 1, 0), Open: usd(10000), High: usd(10000), Low: usd(9500), Close: usd(9500), Volume: 2, Average: usd(9750)},
+					{Start: at(time.April, 1, 0), Open: usd(9800), High: usd(9800), Low: usd(9800), Close: usd(9800), Volume: 1, Average: usd(9800)},
+				},
+			},
+		},
+		{
+			name:     "one series per kind",
+			interval: IntervalDay,
+			points: []PricePoint{
+				{Kind: "ask", Price: usd(13000), RecordedAt: at(time.March, 2, 8)},
+				{Kind: "ask", Price: usd(12500), RecordedAt: at(time.March, 2, 9)},
+				sale(at(time.March, 2, 10), 12000),
+			},
+			want: map[string][]model.PriceCandle{
+				"ask": {
+					{Start: at(time.March, 2, 0), Open: usd(13000), High: usd(13000), Low: usd(12500), Close: usd(12500), Volume: 2, Average: usd(12750)},
+				},
+				"sale": {
+					{Start: at(time.March, 2, 0), Open: usd(12000), High: usd(12000), Low: usd(12000), Close: usd(12000), Volume: 1, Average: usd(12000)},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Candles(tt.points, tt.interval)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package market
+
+import (
+	"database/sql"
+	"math"
+	"sync"
+	"time"
+
+	"grailify/internal/currency"
+	"grailify/internal/model"
+)
+
+// salesVersion identifies the state of an item's sale history. Sales are only
+// ever appended, so a new sale always changes the count or the highest id.
+type salesVersion struct {
+	Count int
+	MaxID int
+}
+
+// statsMaxAge bounds how long cached stats are kept without new sales, so the
+// 52-week window keeps moving for items that stop selling.
+const statsMaxAge = time.Hour
+
+type cachedStats struct {
+	version    salesVersion
+	computedAt time.Time
+	stats      model.ItemStats
+}
+
+// Stats computes per-item market statistics from price_history. Results are
+// cached per item and recomputed as soon as a new sale is recorded.
+type Stats struct {
+	DB *sql.DB
+
+	mu    sync.Mutex
+	cache map[int]cachedStats
+}
+
+func (s *Stats) For(itemID int) (model.ItemStats, error) {
+	var version salesVersion
+	err := s.DB.QueryRow(
+		"SELECT COUNT(*), COALESCE(MAX(id), 0) FROM price_history WHERE item_id = ? AND type = 'sale'",
+		itemID,
+	).Scan(&version.Count, &version.MaxID)
+	if err != nil {
+		return model.ItemStats{}, err
+	}
+
+	s.mu.Lock()
+	cached, ok := s.cache[itemID]
+	s.mu.Unlock()
+	if ok && cached.version == version && time.Since(cached.computedAt) < statsMaxAge {
+		return cached.stats, nil
+	}
+
+	stats, err := s.compute(itemID)
+	if err != nil {
+		return stats, err
+	}
+
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = make(map[int]cachedStats)
+	}
+	s.cache[itemID] = cachedStats{version: version, computedAt: time.Now(), stats: stats}
+	s.mu.Unlock()
+	return stats, nil
+}
+
+func (s *Stats) compute(itemID int) (model.ItemStats, error) {
+	stats := model.ItemStats{ItemID: itemID, RetailPrice: model.Cents(0, currency.Base)}
+
+	if err := s.DB.QueryRow("SELECT price FROM items WHERE id = ?", itemID).Scan(&stats.RetailPrice); err != nil {
+		return stats, err
+	}
+
+	lastSale := model.Cents(0, currency.Base)
+	var lastSaleAt time.Time
+	err := s.DB.QueryRow(`
+		SELECT price, recorded_at, (SELECT COUNT(*) FROM price_history WHERE item_id = ? AND type = 'sale')
+		FROM price_history
+		WHERE item_id = ? AND type = 'sale'
+		ORDER BY recorded_at DESC, id DESC
+		LIMIT 1
+	`, itemID, itemID).Scan(&lastSale, &lastSaleAt, &stats.SalesCount)
+	if err == sql.ErrNoRows {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+	stats.LastSale = &lastSale
+	stats.LastSaleAt = &lastSaleAt
+	if stats.RetailPrice.IsPositive() {
+		premium := math.Round(float64(lastSale.Amount-stats.RetailPrice.Amount)/float64(stats.RetailPrice.Amount)*10000) / 100
+		stats.PremiumPercent = &premium
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT price
+		FROM price_history
+		WHERE item_id = ? AND type = 'sale' AND recorded_at >= ?
+		ORDER BY recorded_at ASC, id ASC
+	`, itemID, time.Now().AddDate(0, 0, -52*7))
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	var prices []model.Money
+	for rows.Next() {
+		price := model.Cents(0, currency.Base)
+		if err := rows.Scan(&price); err != nil {
+			return stats, err
+		}
+		prices = append(prices, price)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	stats.SalesLast52Weeks = len(prices)
+	if len(prices) == 0 {
+		return stats, nil
+	}
+	high, low := prices[0], prices[0]
+	for _, price := range prices[1:] {
+		if price.Amount > high.Amount {
+			high = price
+		}
+		if price.Amount < low.Amount {
+			low = price
+		}
+	}
+	stats.High52Week = &high
+	stats.Low52Week = &low
+	stats.VolatilityPercent = volatility(prices)
+	return stats, nil
+}
+
+// volatility is the standard deviation of the log returns between
+// consecutive sales, as a percentage. It needs at least three sales.
+func volatility(prices []model.Money) *float64 {
+	var returns []float64
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1].IsPositive() && prices[i].IsPositive() {
+			returns = append(returns, math.Log(prices[i].Float()/prices[i-1].Float()))
+		}
+	}
+	if len(returns) < 2 {
+		return nil
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	v := math.Round(math.Sqrt(variance)*10000) / 100
+	return &v
+}
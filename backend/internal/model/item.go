@@ -13,7 +13,8 @@ type Item struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Brand       string    `json:"brand"`
-	Price       float64   `json:"price"`
+	Price       Money     `json:"price"`
+	Currency    string    `json:"currency,omitempty"`
 	ItemsSold   int       `json:"itemsSold"` 
 	CategoryID  int       `json:"category_id"`
 	ReleaseDate time.Time `json:"release_date"`
@@ -24,16 +25,49 @@ type Item struct {
 type PriceHistory struct {
 	ID         int       `json:"id"`
 	ItemID     int       `json:"item_id"`
-	Price      float64   `json:"price"`
+	Price      Money     `json:"price"`
 	Type       string    `json:"type"`
 	RecordedAt time.Time `json:"recorded_at"`
 }
 
+type PriceCandle struct {
+	Start   time.Time `json:"start"`
+	Open    Money     `json:"open"`
+	High    Money     `json:"high"`
+	Low     Money     `json:"low"`
+	Close   Money     `json:"close"`
+	Volume  int       `json:"volume"`
+	Average Money     `json:"average"`
+}
+
+type PriceHistorySeries struct {
+	ItemID   int                      `json:"itemId"`
+	Size     string                   `json:"size,omitempty"`
+	Interval string                   `json:"interval"`
+	From     time.Time                `json:"from"`
+	To       time.Time                `json:"to"`
+	Series   map[string][]PriceCandle `json:"series"`
+}
+
+type ItemStats struct {
+	ItemID            int        `json:"itemId"`
+	RetailPrice       Money      `json:"retailPrice"`
+	LastSale          *Money     `json:"lastSale"`
+	LastSaleAt        *time.Time `json:"lastSaleAt"`
+	High52Week        *Money     `json:"high52Week"`
+	Low52Week         *Money     `json:"low52Week"`
+	SalesCount        int        `json:"salesCount"`
+	SalesLast52Weeks  int        `json:"salesLast52Weeks"`
+	VolatilityPercent *float64   `json:"volatilityPercent"`
+	PremiumPercent    *float64   `json:"premiumPercent"`
+}
+
 type User struct {
 	ID           int       `json:"id"`
 	Username     string    `json:"username"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	Role         string    `json:"role,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -69,27 +103,287 @@ type CartItem struct {
 	Name        string  `json:"name"`
 	Brand       string  `json:"brand"`
 	Size        string  `json:"size"`
-	Price       float64 `json:"price"`
+	Price       Money   `json:"price"`
+	Currency    string  `json:"currency,omitempty"`
 	ImageURL    string  `json:"imageUrl"`
+	// Quantity is the number of units wanted, at least 1.
+	Quantity    int     `json:"quantity"`
+}
+
+// CartLine is a line of a stored cart, re-checked against its listing each
+// time the cart is read. Price is what one unit costs now and SavedPrice
+// what it cost when added. Status is available, price_changed,
+// insufficient_stock, sold_out or unavailable (the listing is gone).
+type CartLine struct {
+	ID          int       `json:"id"`
+	ItemID      int       `json:"itemId"`
+	InventoryID int       `json:"inventoryId,omitempty"`
+	Name        string    `json:"name"`
+	Brand       string    `json:"brand"`
+	ImageURL    string    `json:"imageUrl"`
+	Size        string    `json:"size"`
+	Price       Money     `json:"price"`
+	SavedPrice  Money     `json:"savedPrice"`
+	Quantity    int       `json:"quantity"`
+	LineTotal   Money     `json:"lineTotal"`
+	Currency    string    `json:"currency"`
+	Stock       int       `json:"stock"`
+	Status      string    `json:"status"`
+	AddedAt     time.Time `json:"addedAt"`
+}
+
+// Cart is a user's stored cart. Subtotal covers the lines that can be bought
+// now; Currency is empty when lines are priced in more than one currency.
+type Cart struct {
+	Items    []CartLine `json:"items"`
+	Subtotal Money      `json:"subtotal"`
+	Currency string     `json:"currency"`
 }
 
 type Order struct {
-    ID          int         `json:"id"`
-    UserID      int         `json:"userId"`
-    TotalAmount float64     `json:"totalAmount"`
-    Status      string      `json:"status"`
-    CreatedAt   time.Time   `json:"createdAt"`
-    Items       []OrderItem `json:"items"`
+    ID                int         `json:"id"`
+    UserID            int         `json:"userId"`
+    SubtotalAmount    Money       `json:"subtotalAmount"`
+    TaxAmount         Money       `json:"taxAmount"`
+    ShippingAmount    Money       `json:"shippingAmount"`
+    TotalAmount       Money       `json:"totalAmount"`
+    Currency          string      `json:"currency"`
+    Status            string      `json:"status"`
+    ShippingAddressID int         `json:"shippingAddressId,omitempty"`
+    ShippingMethodID  int         `json:"shippingMethodId,omitempty"`
+    CreatedAt         time.Time   `json:"createdAt"`
+    Items             []OrderItem `json:"items"`
+}
+
+// TaxLine is one tax charged on an order. Rate is a fraction, e.g. 0.2 for
+// 20% VAT.
+type TaxLine struct {
+	Name    string  `json:"name"`
+	Country string  `json:"country"`
+	Region  string  `json:"region,omitempty"`
+	Rate    float64 `json:"rate"`
+	Taxable Money   `json:"taxable"`
+	Amount  Money   `json:"amount"`
+}
+
+// CheckoutQuote is what an order would cost before it is placed.
+type CheckoutQuote struct {
+	Subtotal        Money            `json:"subtotal"`
+	Tax             Money            `json:"tax"`
+	Shipping        Money            `json:"shipping"`
+	Total           Money            `json:"total"`
+	Currency        string           `json:"currency"`
+	TaxLines        []TaxLine        `json:"taxLines"`
+	ShippingMethod  ShippingOption   `json:"shippingMethod"`
+	ShippingOptions []ShippingOption `json:"shippingOptions"`
+}
+
+// ShippingOption is a shipping method and what it costs for one order.
+type ShippingOption struct {
+	MethodID int    `json:"methodId"`
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Carrier  string `json:"carrier"`
+	Price    Money  `json:"price"`
+}
+
+// Shipment is one tracked parcel for an order. Kind is inbound (seller to
+// authentication), outbound (to the buyer) or return (back to the seller).
+type Shipment struct {
+	ID             int             `json:"id"`
+	OrderID        int             `json:"orderId"`
+	Kind           string          `json:"kind"`
+	SellerID       int             `json:"sellerId,omitempty"`
+	Carrier        string          `json:"carrier"`
+	TrackingNumber string          `json:"trackingNumber"`
+	Status         string          `json:"status"`
+	OrderItemIDs   []int           `json:"orderItemIds"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	Events         []ShipmentEvent `json:"events"`
+}
+
+// Authentication is the inspection verdict on one sold line: "pass" or
+// "fail". Photos are URLs in our media storage.
+type Authentication struct {
+	ID               int       `json:"id"`
+	OrderItemID      int       `json:"orderItemId"`
+	Verdict          string    `json:"verdict"`
+	Notes            string    `json:"notes,omitempty"`
+	Photos           []string  `json:"photos"`
+	AuthenticatorID  int       `json:"authenticatorId,omitempty"`
+	RefundID         int       `json:"refundId,omitempty"`
+	ReturnShipmentID int       `json:"returnShipmentId,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// AuthenticationQueueItem is a sold line waiting to be inspected. Carrier,
+// TrackingNumber and ShippedAt describe the seller's inbound parcel and are
+// empty for store stock.
+type AuthenticationQueueItem struct {
+	OrderItemID    int        `json:"orderItemId"`
+	OrderID        int        `json:"orderId"`
+	ItemID         int        `json:"itemId"`
+	ItemName       string     `json:"itemName"`
+	ItemImageURL   string     `json:"itemImageUrl,omitempty"`
+	Size           string     `json:"size"`
+	SellerID       int        `json:"sellerId,omitempty"`
+	Carrier        string     `json:"carrier,omitempty"`
+	TrackingNumber string     `json:"trackingNumber,omitempty"`
+	ShippedAt      *time.Time `json:"shippedAt,omitempty"`
+	OrderedAt      time.Time  `json:"orderedAt"`
+}
+
+type ShipmentEvent struct {
+	ID          int       `json:"id"`
+	Status      string    `json:"status"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+type OrderStatusTransition struct {
+	ID          int       `json:"id"`
+	OrderID     int       `json:"orderId"`
+	FromStatus  string    `json:"fromStatus,omitempty"`
+	ToStatus    string    `json:"toStatus"`
+	ActorUserID int       `json:"actorUserId,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
 }
 
 type OrderItem struct {
     ID              int     `json:"id"`
     OrderID         int     `json:"orderId"`
     ItemID          int     `json:"itemId"`
+    InventoryID     int     `json:"inventoryId,omitempty"`
     Quantity        int     `json:"quantity"`
-    PriceAtPurchase float64 `json:"priceAtPurchase"`
+    PriceAtPurchase Money   `json:"priceAtPurchase"`
+    LineTotal       Money   `json:"lineTotal"`
     ItemName        string  `json:"itemName,omitempty"` 
     ItemImageURL    string  `json:"itemImageUrl,omitempty"` 
+    Size            string  `json:"size,omitempty"`
+    SellerID        int     `json:"sellerId,omitempty"`
+    Seller          string  `json:"seller,omitempty"`
+}
+
+type Refund struct {
+	ID               int          `json:"id"`
+	OrderID          int          `json:"orderId"`
+	Amount           Money        `json:"amount"`
+	Reason           string       `json:"reason,omitempty"`
+	ProviderRefundID string       `json:"providerRefundId,omitempty"`
+	CreatedAt        time.Time    `json:"createdAt"`
+	Items            []RefundItem `json:"items"`
+}
+
+type RefundItem struct {
+	OrderItemID int     `json:"orderItemId"`
+	Quantity    int     `json:"quantity"`
+	Amount      Money   `json:"amount"`
+}
+
+type LedgerEntry struct {
+	ID            int       `json:"id"`
+	TransactionID int       `json:"transactionId"`
+	Kind          string    `json:"kind"`
+	OrderID       int       `json:"orderId,omitempty"`
+	OrderItemID   int       `json:"orderItemId,omitempty"`
+	Amount        Money     `json:"amount"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// SellerSale is one order line sold by a seller. Price and ListingPrice are
+// the unit price paid and the listing's asking price, in PriceCurrency (the
+// order currency). Gross, Fees and Net are what the seller was credited for
+// the line, less any refunds, in Currency (the base currency).
+type SellerSale struct {
+	OrderItemID      int       `json:"orderItemId"`
+	OrderID          int       `json:"orderId"`
+	ItemID           int       `json:"itemId"`
+	ItemName         string    `json:"itemName"`
+	ItemImageURL     string    `json:"itemImageUrl"`
+	InventoryID      int       `json:"inventoryId,omitempty"`
+	Size             string    `json:"size"`
+	Quantity         int       `json:"quantity"`
+	RefundedQuantity int       `json:"refundedQuantity"`
+	Price            Money     `json:"price"`
+	ListingPrice     Money     `json:"listingPrice"`
+	PriceCurrency    string    `json:"priceCurrency"`
+	Currency         string    `json:"currency"`
+	Gross            Money     `json:"gross"`
+	Fees             Money     `json:"fees"`
+	Net              Money     `json:"net"`
+	Status           string    `json:"status"`
+	SoldAt           time.Time `json:"soldAt"`
+}
+
+// SalesSummary aggregates a seller's sales in the base currency. Units leave
+// out refunded units. AverageListingPrice is what the sold units were listed
+// at, to compare with AverageSalePrice, what they fetched.
+type SalesSummary struct {
+	UnitsSold           int    `json:"unitsSold"`
+	Gross               Money  `json:"gross"`
+	Fees                Money  `json:"fees"`
+	Net                 Money  `json:"net"`
+	AverageSalePrice    Money  `json:"averageSalePrice"`
+	AverageListingPrice Money  `json:"averageListingPrice"`
+	Currency            string `json:"currency"`
+}
+
+type SellerBalance struct {
+	SellerID  int     `json:"sellerId"`
+	Balance   Money   `json:"balance"`
+	Sales     Money   `json:"sales"`
+	Fees      Money   `json:"fees"`
+	Payouts   Money   `json:"payouts"`
+	Reversals Money   `json:"reversals"`
+}
+
+type FeeBreakdown struct {
+	Price          Money   `json:"price"`
+	CommissionRate float64 `json:"commissionRate"`
+	Commission     Money   `json:"commission"`
+	ProcessingFee  Money   `json:"processingFee"`
+	TotalFees      Money   `json:"totalFees"`
+	NetPayout      Money   `json:"netPayout"`
+}
+
+type Bid struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"userId"`
+	ItemID      int       `json:"itemId"`
+	ItemName    string    `json:"itemName,omitempty"`
+	Size        string    `json:"size"`
+	Price       Money     `json:"price"`
+	Currency    string    `json:"currency"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	OrderID     int       `json:"orderId,omitempty"`
+	FilledPrice *Money    `json:"filledPrice,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type ExchangeRate struct {
+	Currency  string    `json:"currency"`
+	Rate      float64   `json:"rate"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type BookLevel struct {
+	Price    Money   `json:"price"`
+	Quantity int     `json:"quantity"`
+	Orders   int     `json:"orders"`
+}
+
+type OrderBook struct {
+	ItemID     int         `json:"itemId"`
+	Size       string      `json:"size,omitempty"`
+	Currency   string      `json:"currency"`
+	Asks       []BookLevel `json:"asks"`
+	Bids       []BookLevel `json:"bids"`
+	HighestBid *Money      `json:"highestBid"`
+	LowestAsk  *Money      `json:"lowestAsk"`
+	Spread     *Money      `json:"spread"`
 }
 
 type UserListing struct {
@@ -98,7 +392,7 @@ type UserListing struct {
 	ItemName     string  `json:"itemName"`
 	ItemImageURL string  `json:"itemImageUrl"`
 	Size         string  `json:"size"`
-	Price        float64 `json:"price"`
+	Price        Money   `json:"price"`
 	Stock        int     `json:"stock"`
 }
 
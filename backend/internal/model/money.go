@@ -0,0 +1,218 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAmount = errors.New("model: invalid money amount")
+
+// Money is an exact amount in minor units (cents) of a currency. Every
+// currency we support has two decimal places. Money encodes to JSON as a plain
+// decimal number so existing clients keep working; where clients need the
+// currency it is sent in a separate field. Arithmetic assumes both operands
+// are in the same currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func Cents(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// NewMoney rounds a float amount to the nearest cent. Use it only at the edges,
+// for values that arrive as floats.
+func NewMoney(amount float64, currency string) Money {
+	return Money{Amount: int64(math.Round(amount * 100)), Currency: currency}
+}
+
+// ParseMoney reads a decimal string such as "159.99" exactly. Digits past the
+// second decimal place are rounded half away from zero.
+func ParseMoney(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return Money{}, ErrInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, frac} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return Money{}, ErrInvalidAmount
+			}
+		}
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/100-1 {
+		return Money{}, ErrInvalidAmount
+	}
+	cents := units * 100
+	for i := 0; i < 2; i++ {
+		cents += int64(digitAt(frac, i)) * int64(math.Pow10(1-i))
+	}
+	if digitAt(frac, 2) >= 5 {
+		cents++
+	}
+	if negative {
+		cents = -cents
+	}
+	return Money{Amount: cents, Currency: currency}, nil
+}
+
+func digitAt(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	return int(s[i] - '0')
+}
+
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: m.currencyWith(o)}
+}
+
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.currencyWith(o)}
+}
+
+func (m Money) Mul(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// MulRate multiplies by a rate such as a commission percentage, rounding to
+// the nearest cent.
+func (m Money) MulRate(rate float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * rate)), Currency: m.Currency}
+}
+
+// Split scales m by part/whole, rounding to the nearest cent. It is used to
+// share an amount out over units, e.g. refunding 2 of 3 units.
+func (m Money) Split(part, whole int) Money {
+	if whole == 0 {
+		return Money{Currency: m.Currency}
+	}
+	return m.MulRate(float64(part) / float64(whole))
+}
+
+func (m Money) currencyWith(o Money) string {
+	if m.Currency == "" {
+		return o.Currency
+	}
+	return m.Currency
+}
+
+func (m Money) In(currency string) Money {
+	return Money{Amount: m.Amount, Currency: currency}
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+
+// Float is the amount in major units. It is for statistics and display maths,
+// never for further money arithmetic.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / 100
+}
+
+// String formats the amount as a plain decimal, e.g. "159.99" or "-5.00".
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a numeric string. The currency is left
+// unchanged.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(strings.Trim(s, `"`), m.Currency)
+	if err != nil {
+		if f, ferr := strconv.ParseFloat(s, 64); ferr == nil {
+			*m = NewMoney(f, m.Currency)
+			return nil
+		}
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads a DECIMAL column. The currency lives in its own column and is
+// left unchanged.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		m.Amount = 0
+	case []byte:
+		parsed, err := ParseMoney(string(v), m.Currency)
+		if err != nil {
+			return err
+		}
+		m.Amount = parsed.Amount
+	case string:
+		parsed, err := ParseMoney(v, m.Currency)
+		if err != nil {
+			return err
+		}
+		m.Amount = parsed.Amount
+	case float64:
+		m.Amount = int64(math.Round(v * 100))
+	case int64:
+		m.Amount = v * 100
+	default:
+		return fmt.Errorf("model: cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// Value writes the amount as an exact decimal string.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// NullMoney is Money from a nullable column.
+type NullMoney struct {
+	Money Money
+	Valid bool
+}
+
+func (n *NullMoney) Scan(src interface{}) error {
+	if src == nil {
+		n.Money, n.Valid = Money{}, false
+		return nil
+	}
+	n.Valid = true
+	return n.Money.Scan(src)
+}
+
+func (n NullMoney) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Money.Value()
+}
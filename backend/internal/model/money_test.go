@@ -0,0 +1,72 @@
+package model
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "159.99", want: 15999},
+		{in: "10", want: 1000},
+		{in: "0.5", want: 50},
+		{in: ".5", want: 50},
+		{in: " 4.20 ", want: 420},
+		{in: "+3", want: 300},
+		{in: "-2.50", want: -250},
+		{in: "-.5", want: -50},
+		{in: "1.004", want: 100},
+		{in: "1.005", want: 101},
+		{in: "1.995", want: 200},
+		{in: "-1.005", want: -101},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1.2a", wantErr: true},
+		{in: "1e5", wantErr: true},
+		{in: "1,50", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, "EUR")
+		if tt.wantErr {
+			if err != ErrInvalidAmount {
+				t.Errorf("ParseMoney(%q) error = %v, want ErrInvalidAmount", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != Cents(tt.want, "EUR") {
+			t.Errorf("ParseMoney(%q) = %+v, want %d EUR", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		amount      int64
+		part, whole int
+		want        int64
+	}{
+		{amount: 1000, part: 1, whole: 1, want: 1000},
+		{amount: 1000, part: 1, whole: 3, want: 333},
+		{amount: 1000, part: 2, whole: 3, want: 667},
+		{amount: 1000, part: 0, whole: 5, want: 0},
+		{amount: 1000, part: 3, whole: 0, want: 0},
+		{amount: 1, part: 1, whole: 2, want: 1},
+		{amount: -1000, part: 1, whole: 3, want: -333},
+		{amount: -1, part: 1, whole: 2, want: -1},
+	}
+	for _, tt := range tests {
+		got := Cents(tt.amount, "GBP").Split(tt.part, tt.whole)
+		if got != Cents(tt.want, "GBP") {
+			t.Errorf("Cents(%d).Split(%d, %d) = %+v, want %d GBP", tt.amount, tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
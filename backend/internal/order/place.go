@@ -0,0 +1,223 @@
+package order
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"grailify/internal/currency"
+	"grailify/internal/fees"
+	"grailify/internal/ledger"
+	"grailify/internal/model"
+	"grailify/internal/payment"
+	"grailify/internal/tax"
+)
+
+// Line is a number of units bought from a seller listing at an agreed unit
+// price, in the currency of the placement. ListingPrice is the listing's
+// asking price at the time, which a filled bid may exceed; it defaults to
+// Price.
+type Line struct {
+	InventoryID  int
+	ItemID       int
+	CategoryID   int
+	SellerID     int
+	SizeID       sql.NullInt64
+	Price        model.Money
+	ListingPrice model.Money
+	Quantity     int
+}
+
+// Amount is the price of all units on the line.
+func (l Line) Amount() model.Money {
+	return l.Price.Mul(l.Quantity)
+}
+
+// Placement describes an order to place. Tax and Shipping are charged on top
+// of the lines and are in the placement currency too. ShippingAddressID and
+// ShippingMethodID may be zero for orders with nowhere to ship yet.
+type Placement struct {
+	BuyerID           int
+	Currency          string
+	Lines             []Line
+	Tax               []model.TaxLine
+	Shipping          model.Money
+	ShippingAddressID int
+	ShippingMethodID  int
+	PaymentMethodID   int
+	PaymentToken      string
+	Note              string
+}
+
+type Placed struct {
+	OrderID         int
+	Total           model.Money
+	AuthorizationID string
+}
+
+func Total(lines []Line, code string) model.Money {
+	total := model.Cents(0, code)
+	for _, line := range lines {
+		total = total.Add(line.Amount())
+	}
+	return total
+}
+
+// Place writes a new order with its lines, takes the stock, credits sellers,
+// records each sale in price_history and charges the buyer, leaving the order
+// paid. The caller must already hold locks on the inventory rows and have
+// checked there is enough stock.
+//
+// The buyer is charged while those locks are held, so provider should be
+// wrapped with payment.WithTimeout to bound how long a slow gateway can block
+// other checkouts of the same listings.
+//
+// If Place returns an error the buyer has not been charged. If it succeeds
+// but the caller then fails to commit, the caller must refund
+// Placed.AuthorizationID.
+func Place(tx *sql.Tx, provider payment.Provider, p Placement) (Placed, error) {
+	if p.Currency == "" {
+		p.Currency = currency.Base
+	}
+	subtotal := Total(p.Lines, p.Currency)
+	taxTotal := tax.Total(p.Tax, p.Currency)
+	shipping := p.Shipping.In(p.Currency)
+	placed := Placed{Total: subtotal.Add(taxTotal).Add(shipping)}
+
+	// Sellers are credited and sales recorded in the base currency.
+	rates, err := currency.Load(tx)
+	if err != nil {
+		return placed, err
+	}
+
+	var shippingAddressID, shippingMethodID sql.NullInt64
+	if p.ShippingAddressID > 0 {
+		shippingAddressID = sql.NullInt64{Int64: int64(p.ShippingAddressID), Valid: true}
+	}
+	if p.ShippingMethodID > 0 {
+		shippingMethodID = sql.NullInt64{Int64: int64(p.ShippingMethodID), Valid: true}
+	}
+	result, err := tx.Exec(
+		"INSERT INTO orders (user_id, subtotal_amount, tax_amount, shipping_amount, total_amount, currency, status, shipping_address_id, shipping_method_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		p.BuyerID, subtotal, taxTotal, shipping, placed.Total, p.Currency, string(StatusPendingPayment), shippingAddressID, shippingMethodID,
+	)
+	if err != nil {
+		return placed, err
+	}
+	orderID, err := result.LastInsertId()
+	if err != nil {
+		return placed, err
+	}
+	placed.OrderID = int(orderID)
+
+	if err := Record(tx, placed.OrderID, "", StatusPendingPayment, p.BuyerID, p.Note); err != nil {
+		return placed, err
+	}
+	if err := recordTax(tx, placed.OrderID, p.Tax); err != nil {
+		return placed, fmt.Errorf("record tax: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO order_items (order_id, item_id, inventory_id, seller_user_id, size_id, size, quantity, price_at_purchase, listing_price) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return placed, err
+	}
+	defer stmt.Close()
+
+	for _, line := range p.Lines {
+		var sellerID sql.NullInt64
+		if line.SellerID > 0 {
+			sellerID = sql.NullInt64{Int64: int64(line.SellerID), Valid: true}
+		}
+		size, err := sizeLabel(tx, line.SizeID)
+		if err != nil {
+			return placed, fmt.Errorf("load size of inventory %d: %w", line.InventoryID, err)
+		}
+		listingPrice := line.ListingPrice
+		if listingPrice.IsZero() {
+			listingPrice = line.Price
+		}
+		itemResult, err := stmt.Exec(placed.OrderID, line.ItemID, line.InventoryID, sellerID, line.SizeID, size, line.Quantity, line.Price, listingPrice)
+		if err != nil {
+			return placed, fmt.Errorf("insert order item %d: %w", line.ItemID, err)
+		}
+		if _, err := tx.Exec("UPDATE item_inventory SET stock = stock - ? WHERE id = ?", line.Quantity, line.InventoryID); err != nil {
+			return placed, fmt.Errorf("decrement stock for inventory %d: %w", line.InventoryID, err)
+		}
+
+		orderItemID, err := itemResult.LastInsertId()
+		if err != nil {
+			return placed, err
+		}
+		basePrice, err := rates.Convert(line.Price.In(p.Currency), currency.Base)
+		if err != nil {
+			return placed, fmt.Errorf("convert %s to %s: %w", p.Currency, currency.Base, err)
+		}
+		if line.SellerID > 0 {
+			if err := creditSeller(tx, line, basePrice, placed.OrderID, int(orderItemID)); err != nil {
+				return placed, fmt.Errorf("credit seller %d: %w", line.SellerID, err)
+			}
+		}
+
+		sale := Sale{ItemID: line.ItemID, SizeID: line.SizeID, SellerID: line.SellerID, OrderItemID: int(orderItemID), Price: basePrice}
+		for range line.Quantity {
+			if err := RecordSale(tx, sale); err != nil {
+				return placed, fmt.Errorf("record sale of item %d: %w", line.ItemID, err)
+			}
+		}
+	}
+
+	placed.AuthorizationID, err = provider.Authorize(p.PaymentToken, placed.Total, fmt.Sprintf("order-%d", placed.OrderID))
+	if err != nil {
+		return placed, err
+	}
+
+	if err := provider.Capture(placed.AuthorizationID, placed.Total); err != nil {
+		if voidErr := provider.Void(placed.AuthorizationID); voidErr != nil {
+			log.Printf("Failed to void authorization %s for order %d: %v", placed.AuthorizationID, placed.OrderID, voidErr)
+		}
+		return placed, err
+	}
+
+	// From here on the buyer has been charged, so any failure must refund.
+	if err := recordPayment(tx, provider, p, placed); err != nil {
+		if _, refundErr := provider.Refund(placed.AuthorizationID, placed.Total); refundErr != nil {
+			log.Printf("Failed to refund authorization %s for abandoned order %d: %v", placed.AuthorizationID, placed.OrderID, refundErr)
+		}
+		return placed, err
+	}
+
+	return placed, nil
+}
+
+func recordPayment(tx *sql.Tx, provider payment.Provider, p Placement, placed Placed) error {
+	_, err := tx.Exec(
+		"INSERT INTO payments (order_id, payment_method_id, provider, authorization_id, amount, currency, status) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		placed.OrderID, p.PaymentMethodID, provider.Name(), placed.AuthorizationID, placed.Total, placed.Total.Currency, "captured",
+	)
+	if err != nil {
+		return err
+	}
+	_, err = Advance(tx, placed.OrderID, StatusPaid, p.BuyerID, "Payment captured")
+	return err
+}
+
+// sizeLabel returns the size as buyers see it, "One Size" when there is none.
+func sizeLabel(tx *sql.Tx, sizeID sql.NullInt64) (string, error) {
+	if !sizeID.Valid {
+		return "One Size", nil
+	}
+	var label string
+	err := tx.QueryRow("SELECT size_value FROM sizes WHERE id = ?", sizeID.Int64).Scan(&label)
+	return label, err
+}
+
+// creditSeller credits the seller for every unit on the line. Fees are
+// charged per unit, as the listing fee preview shows them.
+func creditSeller(tx *sql.Tx, line Line, basePrice model.Money, orderID, orderItemID int) error {
+	schedule, err := fees.ScheduleFor(tx, line.CategoryID)
+	if err != nil {
+		return err
+	}
+	unitFees := schedule.Quote(basePrice).TotalFees
+	return ledger.RecordSale(tx, line.SellerID, orderID, orderItemID, basePrice.Mul(line.Quantity), unitFees.Mul(line.Quantity))
+}
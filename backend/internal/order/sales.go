@@ -0,0 +1,87 @@
+package order
+
+import (
+	"database/sql"
+
+	"grailify/internal/model"
+)
+
+const (
+	SaleSourceOrder  = "order"
+	SaleSourceManual = "manual"
+)
+
+// Sale is one unit that changed hands, priced in the base currency.
+// RecordedBy is only set for manual sales, which have no order line to trace
+// them back to.
+type Sale struct {
+	ItemID      int
+	SizeID      sql.NullInt64
+	SellerID    int
+	OrderItemID int
+	Price       model.Money
+	Source      string
+	RecordedBy  int
+}
+
+// RecordSale appends the sale to price_history and bumps the item's
+// items_sold counter, which drives the trending rankings.
+func RecordSale(tx *sql.Tx, sale Sale) error {
+	var sellerID, orderItemID, recordedBy sql.NullInt64
+	if sale.SellerID > 0 {
+		sellerID = sql.NullInt64{Int64: int64(sale.SellerID), Valid: true}
+	}
+	if sale.OrderItemID > 0 {
+		orderItemID = sql.NullInt64{Int64: int64(sale.OrderItemID), Valid: true}
+	}
+	if sale.RecordedBy > 0 {
+		recordedBy = sql.NullInt64{Int64: int64(sale.RecordedBy), Valid: true}
+	}
+	if sale.Source == "" {
+		sale.Source = SaleSourceOrder
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO price_history (item_id, price, type, size_id, seller_user_id, order_item_id, source, recorded_by_user_id) VALUES (?, ?, 'sale', ?, ?, ?, ?, ?)",
+		sale.ItemID, sale.Price, sale.SizeID, sellerID, orderItemID, sale.Source, recordedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE items SET items_sold = items_sold + 1 WHERE id = ?", sale.ItemID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReverseSales takes back quantity units sold on an order line, deleting
+// their price_history rows and lowering items_sold, so refunded sales no
+// longer count towards last sale prices, stats or trending.
+func ReverseSales(tx *sql.Tx, orderItemID, quantity int) error {
+	var itemID int
+	if err := tx.QueryRow("SELECT item_id FROM order_items WHERE id = ?", orderItemID).Scan(&itemID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(
+		"DELETE FROM price_history WHERE order_item_id = ? AND type = 'sale' ORDER BY id DESC LIMIT ?",
+		orderItemID, quantity,
+	)
+	if err != nil {
+		return err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil || removed == 0 {
+		return err
+	}
+	_, err = tx.Exec("UPDATE items SET items_sold = GREATEST(items_sold - ?, 0) WHERE id = ?", removed, itemID)
+	return err
+}
@@ -0,0 +1,61 @@
+package order
+
+import "errors"
+
+type Status string
+
+const (
+	StatusPendingPayment Status = "pending_payment"
+	StatusPaid           Status = "paid"
+	StatusAuthenticating Status = "authenticating"
+	StatusShipped        Status = "shipped"
+	StatusDelivered      Status = "delivered"
+	StatusCancelled      Status = "cancelled"
+	StatusRefunded       Status = "refunded"
+
+	StatusPartiallyRefunded Status = "partially_refunded"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("order: unknown status")
+	ErrInvalidTransition = errors.New("order: illegal status transition")
+	ErrNotFound          = errors.New("order: not found")
+)
+
+var transitions = map[Status][]Status{
+	StatusPendingPayment:    {StatusPaid, StatusCancelled},
+	StatusPaid:              {StatusAuthenticating, StatusCancelled, StatusPartiallyRefunded, StatusRefunded},
+	StatusAuthenticating:    {StatusShipped, StatusPartiallyRefunded, StatusRefunded},
+	StatusShipped:           {StatusDelivered, StatusPartiallyRefunded, StatusRefunded},
+	StatusDelivered:         {StatusPartiallyRefunded, StatusRefunded},
+	StatusPartiallyRefunded: {StatusAuthenticating, StatusShipped, StatusDelivered, StatusPartiallyRefunded, StatusRefunded},
+	StatusCancelled:         {},
+	StatusRefunded:          {},
+}
+
+func ParseStatus(s string) (Status, error) {
+	status := Status(s)
+	if _, ok := transitions[status]; !ok {
+		return "", ErrInvalidStatus
+	}
+	return status, nil
+}
+
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether no further transitions are possible from s.
+func IsTerminal(s Status) bool {
+	return len(transitions[s]) == 0
+}
+
+// IsRefund reports whether s can only be reached by issuing a refund.
+func IsRefund(s Status) bool {
+	return s == StatusRefunded || s == StatusPartiallyRefunded
+}
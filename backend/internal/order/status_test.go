@@ -0,0 +1,40 @@
+package order
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusPendingPayment, StatusPaid, true},
+		{StatusPendingPayment, StatusCancelled, true},
+		{StatusPendingPayment, StatusShipped, false},
+		{StatusPendingPayment, StatusRefunded, false},
+		{StatusPaid, StatusAuthenticating, true},
+		{StatusPaid, StatusCancelled, true},
+		{StatusPaid, StatusRefunded, true},
+		{StatusPaid, StatusShipped, false},
+		{StatusPaid, StatusPendingPayment, false},
+		{StatusAuthenticating, StatusShipped, true},
+		{StatusAuthenticating, StatusCancelled, false},
+		{StatusShipped, StatusDelivered, true},
+		{StatusShipped, StatusAuthenticating, false},
+		{StatusDelivered, StatusPartiallyRefunded, true},
+		{StatusDelivered, StatusShipped, false},
+		{StatusPartiallyRefunded, StatusPartiallyRefunded, true},
+		{StatusPartiallyRefunded, StatusRefunded, true},
+		{StatusPartiallyRefunded, StatusShipped, true},
+		{StatusPartiallyRefunded, StatusCancelled, false},
+		{StatusCancelled, StatusPaid, false},
+		{StatusRefunded, StatusPaid, false},
+		{StatusRefunded, StatusPartiallyRefunded, false},
+		{Status("lost"), StatusPaid, false},
+		{StatusPaid, Status("lost"), false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
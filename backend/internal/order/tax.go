@@ -0,0 +1,50 @@
+package order
+
+import (
+	"database/sql"
+
+	"grailify/internal/model"
+)
+
+func recordTax(tx *sql.Tx, orderID int, lines []model.TaxLine) error {
+	for _, line := range lines {
+		var region sql.NullString
+		if line.Region != "" {
+			region = sql.NullString{String: line.Region, Valid: true}
+		}
+		_, err := tx.Exec(
+			"INSERT INTO order_tax_lines (order_id, name, country, region, rate, taxable_amount, amount) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			orderID, line.Name, line.Country, region, line.Rate, line.Taxable, line.Amount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaxLines returns the tax charged on an order, in the order's currency.
+func TaxLines(db *sql.DB, orderID int, code string) ([]model.TaxLine, error) {
+	rows, err := db.Query(`
+		SELECT name, country, region, rate, taxable_amount, amount
+		FROM order_tax_lines
+		WHERE order_id = ?
+		ORDER BY id ASC
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []model.TaxLine{}
+	for rows.Next() {
+		line := model.TaxLine{Taxable: model.Cents(0, code), Amount: model.Cents(0, code)}
+		var region sql.NullString
+		if err := rows.Scan(&line.Name, &line.Country, &region, &line.Rate, &line.Taxable, &line.Amount); err != nil {
+			return nil, err
+		}
+		line.Region = region.String
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
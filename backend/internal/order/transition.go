@@ -0,0 +1,99 @@
+package order
+
+import (
+	"database/sql"
+
+	"grailify/internal/model"
+)
+
+// Record appends a row to the order's status history without touching the
+// order itself. from is empty for the initial status of a new order.
+func Record(tx *sql.Tx, orderID int, from, to Status, actorID int, note string) error {
+	var fromStatus sql.NullString
+	if from != "" {
+		fromStatus = sql.NullString{String: string(from), Valid: true}
+	}
+	var actor sql.NullInt64
+	if actorID > 0 {
+		actor = sql.NullInt64{Int64: int64(actorID), Valid: true}
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO order_status_transitions (order_id, from_status, to_status, actor_user_id, note) VALUES (?, ?, ?, ?, ?)",
+		orderID, fromStatus, string(to), actor, note,
+	)
+	return err
+}
+
+// Advance locks the order row, checks that moving to the requested status is
+// legal, updates the order and records the transition. It returns the status
+// the order was in before the change.
+func Advance(tx *sql.Tx, orderID int, to Status, actorID int, note string) (Status, error) {
+	var current string
+	err := tx.QueryRow("SELECT status FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	from := Status(current)
+	if !CanTransition(from, to) {
+		return from, ErrInvalidTransition
+	}
+
+	if _, err := tx.Exec("UPDATE orders SET status = ? WHERE id = ?", string(to), orderID); err != nil {
+		return from, err
+	}
+	if err := Record(tx, orderID, from, to, actorID, note); err != nil {
+		return from, err
+	}
+	return from, nil
+}
+
+func History(db *sql.DB, orderID int) ([]model.OrderStatusTransition, error) {
+	rows, err := db.Query(`
+		SELECT id, order_id, from_status, to_status, actor_user_id, note, created_at
+		FROM order_status_transitions
+		WHERE order_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []model.OrderStatusTransition{}
+	for rows.Next() {
+		var t model.OrderStatusTransition
+		var fromStatus, note sql.NullString
+		var actor sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.OrderID, &fromStatus, &t.ToStatus, &actor, &note, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.FromStatus = fromStatus.String
+		t.ActorUserID = int(actor.Int64)
+		t.Note = note.String
+		history = append(history, t)
+	}
+	return history, rows.Err()
+}
+
+// RestoreStock returns every unit of the order not already refunded to the
+// inventory row it was sold from. Lines whose listing has since been deleted
+// are skipped. Lines are summed per listing first, as a multi-table UPDATE
+// only changes each inventory row once.
+func RestoreStock(tx *sql.Tx, orderID int) error {
+	_, err := tx.Exec(`
+		UPDATE item_inventory ii
+		JOIN (
+			SELECT inventory_id, SUM(quantity - refunded_quantity) AS units
+			FROM order_items
+			WHERE order_id = ? AND inventory_id IS NOT NULL
+			GROUP BY inventory_id
+		) sold ON sold.inventory_id = ii.id
+		SET ii.stock = ii.stock + sold.units
+	`, orderID)
+	return err
+}
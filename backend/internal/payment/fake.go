@@ -0,0 +1,197 @@
+package payment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"grailify/internal/model"
+)
+
+// DeclinedCardSuffix makes FakeProvider decline any card whose number ends
+// with it, so checkout failures can be exercised locally.
+const DeclinedCardSuffix = "0002"
+
+const fakeTokenPrefix = "tok_fake_"
+
+type fakeAuthorization struct {
+	currency   string
+	authorized int64
+	captured   int64
+	refunded   int64
+	voided     bool
+}
+
+// FakeProvider is an in-process gateway for tests and local development. It
+// never talks to the network and keeps its state in memory for the lifetime
+// of the process. Ids are sequential within a run and carry a prefix taken
+// from the start time, so they never repeat ids stored by an earlier run.
+type FakeProvider struct {
+	mu             sync.Mutex
+	run            string
+	authSeq        int
+	refundSeq      int
+	authorizations map[string]*fakeAuthorization
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		run:            strconv.FormatInt(time.Now().UnixNano(), 36),
+		authorizations: make(map[string]*fakeAuthorization),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "Fake"
+}
+
+func (p *FakeProvider) Tokenize(card Card) (Token, error) {
+	number := strings.ReplaceAll(strings.ReplaceAll(card.Number, " ", ""), "-", "")
+	if len(number) < 12 || len(number) > 19 || !luhnValid(number) {
+		return Token{}, ErrInvalidCard
+	}
+
+	month, err := strconv.Atoi(card.ExpiryMonth)
+	if err != nil || month < 1 || month > 12 {
+		return Token{}, ErrInvalidCard
+	}
+	year, err := strconv.Atoi(card.ExpiryYear)
+	if err != nil || year < time.Now().Year() {
+		return Token{}, ErrInvalidCard
+	}
+
+	lastFour := number[len(number)-4:]
+	sum := sha256.Sum256([]byte(number + "|" + card.ExpiryMonth + "|" + card.ExpiryYear))
+	return Token{
+		ID:          fakeTokenPrefix + lastFour + "_" + hex.EncodeToString(sum[:8]),
+		CardType:    cardType(number),
+		LastFour:    lastFour,
+		ExpiryMonth: fmt.Sprintf("%02d", month),
+		ExpiryYear:  card.ExpiryYear,
+	}, nil
+}
+
+func (p *FakeProvider) Authorize(token string, amount model.Money, reference string) (string, error) {
+	if !strings.HasPrefix(token, fakeTokenPrefix) || len(token) < len(fakeTokenPrefix)+4 {
+		return "", ErrInvalidToken
+	}
+	cents := amount.Amount
+	if cents <= 0 {
+		return "", ErrInvalidAmount
+	}
+	if token[len(fakeTokenPrefix):len(fakeTokenPrefix)+4] == DeclinedCardSuffix {
+		return "", ErrDeclined
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.authSeq++
+	id := fmt.Sprintf("auth_fake_%s_%06d", p.run, p.authSeq)
+	p.authorizations[id] = &fakeAuthorization{currency: amount.Currency, authorized: cents}
+	return id, nil
+}
+
+func (p *FakeProvider) Capture(authorizationID string, amount model.Money) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	auth, ok := p.authorizations[authorizationID]
+	if !ok {
+		return ErrUnknownAuthorization
+	}
+	if auth.voided || auth.captured > 0 {
+		return ErrInvalidState
+	}
+	if amount.Currency != auth.currency {
+		return ErrCurrencyMismatch
+	}
+	cents := amount.Amount
+	if cents <= 0 {
+		return ErrInvalidAmount
+	}
+	if cents > auth.authorized {
+		return ErrAmountExceeded
+	}
+	auth.captured = cents
+	return nil
+}
+
+func (p *FakeProvider) Refund(authorizationID string, amount model.Money) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	auth, ok := p.authorizations[authorizationID]
+	if !ok {
+		return "", ErrUnknownAuthorization
+	}
+	if auth.captured == 0 {
+		return "", ErrInvalidState
+	}
+	if amount.Currency != auth.currency {
+		return "", ErrCurrencyMismatch
+	}
+	cents := amount.Amount
+	if cents <= 0 {
+		return "", ErrInvalidAmount
+	}
+	if auth.refunded+cents > auth.captured {
+		return "", ErrAmountExceeded
+	}
+	auth.refunded += cents
+	p.refundSeq++
+	return fmt.Sprintf("re_fake_%s_%06d", p.run, p.refundSeq), nil
+}
+
+func (p *FakeProvider) Void(authorizationID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	auth, ok := p.authorizations[authorizationID]
+	if !ok {
+		return ErrUnknownAuthorization
+	}
+	if auth.voided || auth.captured > 0 {
+		return ErrInvalidState
+	}
+	auth.voided = true
+	return nil
+}
+
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func cardType(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "Visa"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "Amex"
+	case number[0] == '5' && number[1] >= '1' && number[1] <= '5', strings.HasPrefix(number, "2"):
+		return "MasterCard"
+	case strings.HasPrefix(number, "6"):
+		return "Discover"
+	default:
+		return "Card"
+	}
+}
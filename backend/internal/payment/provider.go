@@ -0,0 +1,49 @@
+package payment
+
+import (
+	"errors"
+
+	"grailify/internal/model"
+)
+
+var (
+	ErrInvalidCard          = errors.New("payment: invalid card details")
+	ErrInvalidToken         = errors.New("payment: unknown payment token")
+	ErrInvalidAmount        = errors.New("payment: amount must be positive")
+	ErrDeclined             = errors.New("payment: card declined")
+	ErrUnknownAuthorization = errors.New("payment: unknown authorization")
+	ErrInvalidState         = errors.New("payment: operation not allowed in current state")
+	ErrAmountExceeded       = errors.New("payment: amount exceeds what is available")
+	ErrCurrencyMismatch     = errors.New("payment: currency differs from the authorization")
+)
+
+// Card holds raw card details. They are only ever passed to Tokenize and are
+// never stored by Grailify.
+type Card struct {
+	Number      string
+	ExpiryMonth string
+	ExpiryYear  string
+	CVC         string
+}
+
+// Token is the provider's reusable reference to a card, plus the display
+// details we are allowed to keep.
+type Token struct {
+	ID          string
+	CardType    string
+	LastFour    string
+	ExpiryMonth string
+	ExpiryYear  string
+}
+
+// Provider is implemented by every payment gateway. Authorize places a hold in
+// the amount's currency, Capture collects some or all of it, Void releases an
+// uncaptured hold and Refund returns captured money.
+type Provider interface {
+	Name() string
+	Tokenize(card Card) (Token, error)
+	Authorize(token string, amount model.Money, reference string) (authorizationID string, err error)
+	Capture(authorizationID string, amount model.Money) error
+	Refund(authorizationID string, amount model.Money) (refundID string, err error)
+	Void(authorizationID string) error
+}
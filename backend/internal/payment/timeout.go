@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"grailify/internal/model"
+)
+
+var ErrTimeout = errors.New("payment: provider did not respond in time")
+
+// DefaultTimeout bounds Authorize and Capture. Checkout calls them while it
+// holds row locks on the listings being bought, so a slow gateway would
+// otherwise stall every checkout for those listings.
+const DefaultTimeout = 10 * time.Second
+
+type timeoutProvider struct {
+	Provider
+	timeout time.Duration
+}
+
+// WithTimeout gives up on Authorize and Capture calls to p that take longer
+// than d and returns ErrTimeout. A call that completes after giving up is
+// undone: a late authorization is voided and a late capture refunded, since
+// the caller has already treated it as failed.
+func WithTimeout(p Provider, d time.Duration) Provider {
+	return &timeoutProvider{Provider: p, timeout: d}
+}
+
+type authorizeResult struct {
+	id  string
+	err error
+}
+
+func (p *timeoutProvider) Authorize(token string, amount model.Money, reference string) (string, error) {
+	done := make(chan authorizeResult, 1)
+	go func() {
+		id, err := p.Provider.Authorize(token, amount, reference)
+		done <- authorizeResult{id, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.id, r.err
+	case <-time.After(p.timeout):
+		go func() {
+			r := <-done
+			if r.err != nil {
+				return
+			}
+			if err := p.Provider.Void(r.id); err != nil {
+				log.Printf("Failed to void late authorization %s for %s: %v", r.id, reference, err)
+			}
+		}()
+		return "", ErrTimeout
+	}
+}
+
+func (p *timeoutProvider) Capture(authorizationID string, amount model.Money) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Provider.Capture(authorizationID, amount)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(p.timeout):
+		go func() {
+			if err := <-done; err != nil {
+				return
+			}
+			if _, err := p.Provider.Refund(authorizationID, amount); err != nil {
+				log.Printf("Failed to refund late capture on authorization %s: %v", authorizationID, err)
+			}
+		}()
+		return ErrTimeout
+	}
+}
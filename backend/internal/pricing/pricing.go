@@ -0,0 +1,137 @@
+package pricing
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"grailify/internal/database"
+	"grailify/internal/model"
+)
+
+const (
+	policyNone          = "none"
+	policyCeilPrefix    = "ceil_"
+	policyPsychological = "psychological"
+)
+
+// Policy rounds a price for display and sale. Every policy only ever rounds
+// up and is idempotent, so prices can be rounded again safely at checkout.
+// The zero value leaves prices unchanged.
+type Policy struct {
+	Name string
+	// stepCents is the ceil_N step in cents.
+	stepCents int64
+	charm     bool
+}
+
+// None leaves prices as they are, to the cent.
+var None = Policy{Name: policyNone}
+
+// Parse reads a policy name: "none", "ceil_N" (round up to a multiple of N)
+// or "psychological" (round up to the next .99).
+func Parse(name string) (Policy, error) {
+	switch {
+	case name == policyNone:
+		return None, nil
+	case name == policyPsychological:
+		return Policy{Name: name, charm: true}, nil
+	case strings.HasPrefix(name, policyCeilPrefix):
+		step, err := strconv.ParseFloat(strings.TrimPrefix(name, policyCeilPrefix), 64)
+		if err != nil || math.IsNaN(step) || math.IsInf(step, 0) {
+			return Policy{}, fmt.Errorf("pricing: invalid step in policy %q", name)
+		}
+		// Steps under half a cent would round to 0 and silently act as none.
+		stepCents := int64(math.Round(step * 100))
+		if stepCents <= 0 {
+			return Policy{}, fmt.Errorf("pricing: step in policy %q must be at least 0.01", name)
+		}
+		return Policy{Name: name, stepCents: stepCents}, nil
+	}
+	return Policy{}, fmt.Errorf("pricing: unknown policy %q", name)
+}
+
+func (p Policy) Apply(price model.Money) model.Money {
+	cents := price.Amount
+	switch {
+	case p.stepCents > 0:
+		cents = (cents + p.stepCents - 1) / p.stepCents * p.stepCents
+	case p.charm && cents > 0:
+		cents = (cents+100)/100*100 - 1
+	}
+	return model.Cents(cents, price.Currency)
+}
+
+type rule struct {
+	categoryID sql.NullInt64
+	currency   sql.NullString
+	policy     Policy
+}
+
+// Rules holds the rounding_policies table. Load it once per request and look
+// policies up with For.
+type Rules struct {
+	rules []rule
+}
+
+func Load(q database.Querier) (Rules, error) {
+	var rules Rules
+	rows, err := q.Query("SELECT category_id, currency, policy FROM rounding_policies")
+	if err != nil {
+		return rules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r rule
+		var name string
+		if err := rows.Scan(&r.categoryID, &r.currency, &name); err != nil {
+			return rules, err
+		}
+		if r.policy, err = Parse(name); err != nil {
+			log.Printf("Skipping rounding policy: %v", err)
+			continue
+		}
+		rules.rules = append(rules.rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// For picks the most specific policy for a category and currency. A rule
+// naming both wins over one naming only the category, which wins over one
+// naming only the currency, which wins over the default row. With no rule at
+// all prices are left unchanged.
+func (rs Rules) For(categoryID int, currency string) Policy {
+	best, bestScore := None, -1
+	for _, r := range rs.rules {
+		score := 0
+		if r.categoryID.Valid {
+			if int(r.categoryID.Int64) != categoryID {
+				continue
+			}
+			score += 2
+		}
+		if r.currency.Valid {
+			if r.currency.String != currency {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r.policy, score
+		}
+	}
+	return best
+}
+
+// PolicyFor loads the rules and returns the policy for one category.
+func PolicyFor(q database.Querier, categoryID int, currency string) (Policy, error) {
+	rules, err := Load(q)
+	if err != nil {
+		return None, err
+	}
+	return rules.For(categoryID, currency), nil
+}
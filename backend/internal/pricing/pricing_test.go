@@ -0,0 +1,84 @@
+package pricing
+
+import (
+	"testing"
+
+	"grailify/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Policy
+		wantErr bool
+	}{
+		{name: "none", want: None},
+		{name: "psychological", want: Policy{Name: "psychological", charm: true}},
+		{name: "ceil_10", want: Policy{Name: "ceil_10", stepCents: 1000}},
+		{name: "ceil_1", want: Policy{Name: "ceil_1", stepCents: 100}},
+		{name: "ceil_0.5", want: Policy{Name: "ceil_0.5", stepCents: 50}},
+		{name: "ceil_0.01", want: Policy{Name: "ceil_0.01", stepCents: 1}},
+		{name: "ceil_0.001", wantErr: true},
+		{name: "ceil_0", wantErr: true},
+		{name: "ceil_-5", wantErr: true},
+		{name: "ceil_", wantErr: true},
+		{name: "ceil_abc", wantErr: true},
+		{name: "ceil_NaN", wantErr: true},
+		{name: "ceil_Inf", wantErr: true},
+		{name: "round_5", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %+v, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		policy string
+		price  int64
+		want   int64
+	}{
+		{policy: "none", price: 12345, want: 12345},
+		{policy: "ceil_10", price: 12345, want: 13000},
+		{policy: "ceil_10", price: 13000, want: 13000},
+		{policy: "ceil_10", price: 0, want: 0},
+		{policy: "ceil_1", price: 12301, want: 12400},
+		{policy: "ceil_0.5", price: 12301, want: 12350},
+		{policy: "ceil_0.01", price: 12301, want: 12301},
+		{policy: "psychological", price: 12345, want: 12399},
+		{policy: "psychological", price: 12399, want: 12399},
+		{policy: "psychological", price: 12400, want: 12499},
+		{policy: "psychological", price: 0, want: 0},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.policy)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.policy, err)
+		}
+		got := p.Apply(model.Cents(tt.price, "USD"))
+		if got != model.Cents(tt.want, "USD") {
+			t.Errorf("%s.Apply(%d) = %+v, want %d USD", tt.policy, tt.price, got, tt.want)
+		}
+		if again := p.Apply(got); again != got {
+			t.Errorf("%s.Apply is not idempotent: %d then %d", tt.policy, got.Amount, again.Amount)
+		}
+	}
+
+	if got := (Policy{}).Apply(model.Cents(12345, "USD")); got.Amount != 12345 {
+		t.Errorf("zero Policy.Apply(12345) = %d, want 12345", got.Amount)
+	}
+}
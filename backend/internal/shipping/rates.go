@@ -0,0 +1,159 @@
+package shipping
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	"grailify/internal/currency"
+	"grailify/internal/database"
+	"grailify/internal/model"
+)
+
+const (
+	ClassSmall    = "small"
+	ClassStandard = "standard"
+	ClassLarge    = "large"
+)
+
+var classRank = map[string]int{ClassSmall: 0, ClassStandard: 1, ClassLarge: 2}
+
+var ErrNoMethod = errors.New("shipping: no shipping method for destination")
+
+type rate struct {
+	methodID int
+	country  string
+	class    string
+	price    model.Money
+}
+
+// Table holds the active shipping methods and their rates. Load it once per
+// request.
+type Table struct {
+	methods []model.ShippingOption
+	rates   []rate
+}
+
+func Load(q database.Querier) (Table, error) {
+	var table Table
+	rows, err := q.Query("SELECT id, code, name, carrier FROM shipping_methods WHERE active ORDER BY id")
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m model.ShippingOption
+		if err := rows.Scan(&m.MethodID, &m.Code, &m.Name, &m.Carrier); err != nil {
+			return table, err
+		}
+		table.methods = append(table.methods, m)
+	}
+	if err := rows.Err(); err != nil {
+		return table, err
+	}
+
+	rateRows, err := q.Query("SELECT method_id, country, weight_class, price FROM shipping_rates")
+	if err != nil {
+		return table, err
+	}
+	defer rateRows.Close()
+	for rateRows.Next() {
+		r := rate{price: model.Cents(0, currency.Base)}
+		var country sql.NullString
+		if err := rateRows.Scan(&r.methodID, &country, &r.class, &r.price); err != nil {
+			return table, err
+		}
+		r.country = strings.ToUpper(country.String)
+		table.rates = append(table.rates, r)
+	}
+	return table, rateRows.Err()
+}
+
+// Options lists every method that delivers to country, cheapest first, with
+// its base-currency price for a parcel of the given weight class. A rate for
+// the country itself wins over the catch-all rate.
+func (t Table) Options(country, class string) []model.ShippingOption {
+	var options []model.ShippingOption
+	for _, m := range t.methods {
+		var price *model.Money
+		for i, r := range t.rates {
+			if r.methodID != m.MethodID || r.class != class {
+				continue
+			}
+			if r.country == country {
+				price = &t.rates[i].price
+				break
+			}
+			if r.country == "" {
+				price = &t.rates[i].price
+			}
+		}
+		if price != nil {
+			m.Price = *price
+			options = append(options, m)
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].Price.Amount < options[j].Price.Amount })
+	return options
+}
+
+// OrderClass is the weight class of the heaviest category in an order.
+func OrderClass(q database.Querier, categoryIDs []int) (string, error) {
+	class := ClassSmall
+	if len(categoryIDs) == 0 {
+		return class, nil
+	}
+	args := make([]interface{}, len(categoryIDs))
+	for i, id := range categoryIDs {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(categoryIDs)), ",")
+	rows, err := q.Query("SELECT weight_class FROM categories WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return class, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return class, err
+		}
+		if classRank[c] > classRank[class] {
+			class = c
+		}
+	}
+	return class, rows.Err()
+}
+
+// Quote prices delivery of an order to country in currency code. methodID
+// picks a method; zero picks the cheapest. It returns the chosen option and
+// every option that was available.
+func Quote(q database.Querier, country string, categoryIDs []int, methodID int, code string) (model.ShippingOption, []model.ShippingOption, error) {
+	var chosen model.ShippingOption
+	table, err := Load(q)
+	if err != nil {
+		return chosen, nil, err
+	}
+	class, err := OrderClass(q, categoryIDs)
+	if err != nil {
+		return chosen, nil, err
+	}
+	rates, err := currency.Load(q)
+	if err != nil {
+		return chosen, nil, err
+	}
+
+	options := table.Options(country, class)
+	for i := range options {
+		if options[i].Price, err = rates.Convert(options[i].Price, code); err != nil {
+			return chosen, nil, err
+		}
+	}
+	for _, option := range options {
+		if methodID == 0 || option.MethodID == methodID {
+			return option, options, nil
+		}
+	}
+	return chosen, options, ErrNoMethod
+}
@@ -0,0 +1,210 @@
+package shipping
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"grailify/internal/model"
+)
+
+// Kinds of shipment. Sellers send inbound parcels to authentication, we send
+// outbound parcels to buyers, and items that fail authentication go back to
+// the seller as returns.
+const (
+	KindInbound  = "inbound"
+	KindOutbound = "outbound"
+	KindReturn   = "return"
+)
+
+const (
+	StatusInTransit      = "in_transit"
+	StatusOutForDelivery = "out_for_delivery"
+	StatusDelivered      = "delivered"
+	StatusException      = "exception"
+)
+
+var (
+	ErrInvalidStatus = errors.New("shipping: unknown shipment status")
+	ErrDuplicate     = errors.New("shipping: tracking number already in use")
+	ErrNotFound      = errors.New("shipping: shipment not found")
+)
+
+func ParseStatus(s string) (string, error) {
+	switch s {
+	case StatusInTransit, StatusOutForDelivery, StatusDelivered, StatusException:
+		return s, nil
+	}
+	return "", ErrInvalidStatus
+}
+
+// Create records a shipment for some lines of an order along with its first
+// tracking event, and fills in s.ID and s.Events.
+func Create(tx *sql.Tx, s *model.Shipment, actorID int, note string) error {
+	var taken int
+	err := tx.QueryRow("SELECT COUNT(*) FROM shipments WHERE carrier = ? AND tracking_number = ?", s.Carrier, s.TrackingNumber).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrDuplicate
+	}
+
+	var seller, actor sql.NullInt64
+	if s.SellerID > 0 {
+		seller = sql.NullInt64{Int64: int64(s.SellerID), Valid: true}
+	}
+	if actorID > 0 {
+		actor = sql.NullInt64{Int64: int64(actorID), Valid: true}
+	}
+	s.Status = StatusInTransit
+	result, err := tx.Exec(
+		"INSERT INTO shipments (order_id, kind, seller_user_id, carrier, tracking_number, status, created_by_user_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		s.OrderID, s.Kind, seller, s.Carrier, s.TrackingNumber, s.Status, actor,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.ID = int(id)
+
+	for _, orderItemID := range s.OrderItemIDs {
+		if _, err := tx.Exec("INSERT INTO shipment_items (shipment_id, order_item_id) VALUES (?, ?)", s.ID, orderItemID); err != nil {
+			return err
+		}
+	}
+
+	event := model.ShipmentEvent{Status: s.Status, Description: note, OccurredAt: time.Now().UTC()}
+	if err := AddEvent(tx, s.ID, &event); err != nil {
+		return err
+	}
+	s.Events = []model.ShipmentEvent{event}
+	return nil
+}
+
+// Lock takes a row lock on a shipment and returns it without its lines or
+// events.
+func Lock(tx *sql.Tx, shipmentID int) (model.Shipment, error) {
+	var s model.Shipment
+	var seller sql.NullInt64
+	err := tx.QueryRow("SELECT id, order_id, kind, seller_user_id, carrier, tracking_number, status, created_at FROM shipments WHERE id = ? FOR UPDATE", shipmentID).
+		Scan(&s.ID, &s.OrderID, &s.Kind, &seller, &s.Carrier, &s.TrackingNumber, &s.Status, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return s, ErrNotFound
+	}
+	s.SellerID = int(seller.Int64)
+	return s, err
+}
+
+// AddEvent appends a tracking event and moves the shipment to its status.
+func AddEvent(tx *sql.Tx, shipmentID int, e *model.ShipmentEvent) error {
+	if _, err := tx.Exec("UPDATE shipments SET status = ? WHERE id = ?", e.Status, shipmentID); err != nil {
+		return err
+	}
+
+	var description, location sql.NullString
+	if e.Description != "" {
+		description = sql.NullString{String: e.Description, Valid: true}
+	}
+	if e.Location != "" {
+		location = sql.NullString{String: e.Location, Valid: true}
+	}
+	result, err := tx.Exec(
+		"INSERT INTO shipment_events (shipment_id, status, description, location, occurred_at) VALUES (?, ?, ?, ?, ?)",
+		shipmentID, e.Status, description, location, e.OccurredAt,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = int(id)
+	return nil
+}
+
+// ForOrder returns an order's shipments, oldest first, each with its lines
+// and tracking events in the order they happened.
+func ForOrder(db *sql.DB, orderID int) ([]model.Shipment, error) {
+	rows, err := db.Query(`
+		SELECT id, order_id, kind, seller_user_id, carrier, tracking_number, status, created_at
+		FROM shipments
+		WHERE order_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	shipments := []model.Shipment{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var s model.Shipment
+		var seller sql.NullInt64
+		if err := rows.Scan(&s.ID, &s.OrderID, &s.Kind, &seller, &s.Carrier, &s.TrackingNumber, &s.Status, &s.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.SellerID = int(seller.Int64)
+		s.OrderItemIDs = []int{}
+		s.Events = []model.ShipmentEvent{}
+		index[s.ID] = len(shipments)
+		shipments = append(shipments, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(shipments) == 0 {
+		return shipments, err
+	}
+
+	ids := make([]interface{}, len(shipments))
+	for i, s := range shipments {
+		ids[i] = s.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+	itemRows, err := db.Query("SELECT shipment_id, order_item_id FROM shipment_items WHERE shipment_id IN ("+placeholders+") ORDER BY order_item_id", ids...)
+	if err != nil {
+		return nil, err
+	}
+	for itemRows.Next() {
+		var shipmentID, orderItemID int
+		if err := itemRows.Scan(&shipmentID, &orderItemID); err != nil {
+			itemRows.Close()
+			return nil, err
+		}
+		s := &shipments[index[shipmentID]]
+		s.OrderItemIDs = append(s.OrderItemIDs, orderItemID)
+	}
+	itemRows.Close()
+	if err := itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	eventRows, err := db.Query(`
+		SELECT id, shipment_id, status, description, location, occurred_at
+		FROM shipment_events
+		WHERE shipment_id IN (`+placeholders+`)
+		ORDER BY occurred_at ASC, id ASC
+	`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer eventRows.Close()
+	for eventRows.Next() {
+		var e model.ShipmentEvent
+		var shipmentID int
+		var description, location sql.NullString
+		if err := eventRows.Scan(&e.ID, &shipmentID, &e.Status, &description, &location, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		e.Description = description.String
+		e.Location = location.String
+		s := &shipments[index[shipmentID]]
+		s.Events = append(s.Events, e)
+	}
+	return shipments, eventRows.Err()
+}
@@ -0,0 +1,197 @@
+package tax
+
+import (
+	"database/sql"
+	"strings"
+
+	"grailify/internal/database"
+	"grailify/internal/model"
+)
+
+// countryAliases maps the country names people type into address forms to
+// the ISO 3166 codes tax_rates is keyed on.
+var countryAliases = map[string]string{
+	"UNITED STATES":            "US",
+	"UNITED STATES OF AMERICA": "US",
+	"USA":                      "US",
+	"UNITED KINGDOM":           "GB",
+	"UK":                       "GB",
+	"GREAT BRITAIN":            "GB",
+	"GERMANY":                  "DE",
+	"FRANCE":                   "FR",
+	"CANADA":                   "CA",
+	"AUSTRALIA":                "AU",
+	"JAPAN":                    "JP",
+}
+
+// Country normalizes a country from an address to its ISO code.
+func Country(country string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if code, ok := countryAliases[country]; ok {
+		return code
+	}
+	return country
+}
+
+// regionAliases maps region names to the codes tax_rates uses, per country.
+// Regions of other countries are matched as given.
+var regionAliases = map[string]map[string]string{
+	"US": {
+		"ALABAMA":              "AL",
+		"ALASKA":               "AK",
+		"ARIZONA":              "AZ",
+		"ARKANSAS":             "AR",
+		"CALIFORNIA":           "CA",
+		"COLORADO":             "CO",
+		"CONNECTICUT":          "CT",
+		"DELAWARE":             "DE",
+		"DISTRICT OF COLUMBIA": "DC",
+		"FLORIDA":              "FL",
+		"GEORGIA":              "GA",
+		"HAWAII":               "HI",
+		"IDAHO":                "ID",
+		"ILLINOIS":             "IL",
+		"INDIANA":              "IN",
+		"IOWA":                 "IA",
+		"KANSAS":               "KS",
+		"KENTUCKY":             "KY",
+		"LOUISIANA":            "LA",
+		"MAINE":                "ME",
+		"MARYLAND":             "MD",
+		"MASSACHUSETTS":        "MA",
+		"MICHIGAN":             "MI",
+		"MINNESOTA":            "MN",
+		"MISSISSIPPI":          "MS",
+		"MISSOURI":             "MO",
+		"MONTANA":              "MT",
+		"NEBRASKA":             "NE",
+		"NEVADA":               "NV",
+		"NEW HAMPSHIRE":        "NH",
+		"NEW JERSEY":           "NJ",
+		"NEW MEXICO":           "NM",
+		"NEW YORK":             "NY",
+		"NORTH CAROLINA":       "NC",
+		"NORTH DAKOTA":         "ND",
+		"OHIO":                 "OH",
+		"OKLAHOMA":             "OK",
+		"OREGON":               "OR",
+		"PENNSYLVANIA":         "PA",
+		"RHODE ISLAND":         "RI",
+		"SOUTH CAROLINA":       "SC",
+		"SOUTH DAKOTA":         "SD",
+		"TENNESSEE":            "TN",
+		"TEXAS":                "TX",
+		"UTAH":                 "UT",
+		"VERMONT":              "VT",
+		"VIRGINIA":             "VA",
+		"WASHINGTON":           "WA",
+		"WEST VIRGINIA":        "WV",
+		"WISCONSIN":            "WI",
+		"WYOMING":              "WY",
+	},
+	"CA": {
+		"ALBERTA":                   "AB",
+		"BRITISH COLUMBIA":          "BC",
+		"MANITOBA":                  "MB",
+		"NEW BRUNSWICK":             "NB",
+		"NEWFOUNDLAND AND LABRADOR": "NL",
+		"NEWFOUNDLAND":              "NL",
+		"NORTHWEST TERRITORIES":     "NT",
+		"NOVA SCOTIA":               "NS",
+		"NUNAVUT":                   "NU",
+		"ONTARIO":                   "ON",
+		"PRINCE EDWARD ISLAND":      "PE",
+		"QUEBEC":                    "QC",
+		"QUÉBEC":                    "QC",
+		"SASKATCHEWAN":              "SK",
+		"YUKON":                     "YT",
+	},
+}
+
+// Region normalizes the state, province or region of an address in country
+// to the code tax_rates is keyed on, e.g. "California" to "CA".
+func Region(country, region string) string {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if code, ok := regionAliases[Country(country)][region]; ok {
+		return code
+	}
+	return region
+}
+
+// Rate is one row of tax_rates. A rate with no region applies to the whole
+// country; regional rates (US state sales tax, Canadian PST) stack on top of
+// it.
+type Rate struct {
+	Name             string
+	Country          string
+	Region           string
+	Rate             float64
+	IncludesShipping bool
+}
+
+// Table holds the tax_rates table. Load it once per request.
+type Table struct {
+	rates []Rate
+}
+
+func Load(q database.Querier) (Table, error) {
+	var table Table
+	rows, err := q.Query("SELECT name, country, region, rate, includes_shipping FROM tax_rates ORDER BY country, region, id")
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Rate
+		var regionCode sql.NullString
+		if err := rows.Scan(&r.Name, &r.Country, &regionCode, &r.Rate, &r.IncludesShipping); err != nil {
+			return table, err
+		}
+		r.Country = Country(r.Country)
+		r.Region = Region(r.Country, regionCode.String)
+		table.rates = append(table.rates, r)
+	}
+	return table, rows.Err()
+}
+
+// For returns every rate that applies to a destination.
+func (t Table) For(country, regionName string) []Rate {
+	country, regionName = Country(country), Region(country, regionName)
+	var rates []Rate
+	for _, r := range t.rates {
+		if r.Country == country && (r.Region == "" || r.Region == regionName) {
+			rates = append(rates, r)
+		}
+	}
+	return rates
+}
+
+// Calculate itemizes the tax on an order shipped to address. Amounts are in
+// the currency of subtotal; an address with no configured rates owes no tax.
+func (t Table) Calculate(address model.UserAddress, subtotal, shipping model.Money) []model.TaxLine {
+	lines := []model.TaxLine{}
+	for _, r := range t.For(address.Country, address.StateProvinceRegion) {
+		taxable := subtotal
+		if r.IncludesShipping {
+			taxable = taxable.Add(shipping)
+		}
+		lines = append(lines, model.TaxLine{
+			Name:    r.Name,
+			Country: r.Country,
+			Region:  r.Region,
+			Rate:    r.Rate,
+			Taxable: taxable,
+			Amount:  taxable.MulRate(r.Rate),
+		})
+	}
+	return lines
+}
+
+func Total(lines []model.TaxLine, code string) model.Money {
+	total := model.Cents(0, code)
+	for _, line := range lines {
+		total = total.Add(line.Amount)
+	}
+	return total
+}
@@ -0,0 +1,69 @@
+package tax
+
+import (
+	"reflect"
+	"testing"
+
+	"grailify/internal/model"
+)
+
+func TestCalculate(t *testing.T) {
+	table := Table{rates: []Rate{
+		{Name: "California Sales Tax", Country: "US", Region: "CA", Rate: 0.0725},
+		{Name: "GST", Country: "CA", Rate: 0.05, IncludesShipping: true},
+		{Name: "BC PST", Country: "CA", Region: "BC", Rate: 0.07},
+		{Name: "VAT", Country: "GB", Rate: 0.20, IncludesShipping: true},
+	}}
+	subtotal := model.Cents(10000, "USD")
+	shipping := model.Cents(1500, "USD")
+
+	tests := []struct {
+		name    string
+		country string
+		region  string
+		want    []model.TaxLine
+	}{
+		{
+			name: "US state by code", country: "US", region: "CA",
+			want: []model.TaxLine{
+				{Name: "California Sales Tax", Country: "US", Region: "CA", Rate: 0.0725, Taxable: subtotal, Amount: model.Cents(725, "USD")},
+			},
+		},
+		{
+			name: "US state by name", country: "United States", region: " california ",
+			want: []model.TaxLine{
+				{Name: "California Sales Tax", Country: "US", Region: "CA", Rate: 0.0725, Taxable: subtotal, Amount: model.Cents(725, "USD")},
+			},
+		},
+		{
+			name: "Canadian province stacks on the national rate", country: "Canada", region: "British Columbia",
+			want: []model.TaxLine{
+				{Name: "GST", Country: "CA", Rate: 0.05, Taxable: model.Cents(11500, "USD"), Amount: model.Cents(575, "USD")},
+				{Name: "BC PST", Country: "CA", Region: "BC", Rate: 0.07, Taxable: subtotal, Amount: model.Cents(700, "USD")},
+			},
+		},
+		{
+			name: "national rate only", country: "uk", region: "London",
+			want: []model.TaxLine{
+				{Name: "VAT", Country: "GB", Rate: 0.20, Taxable: model.Cents(11500, "USD"), Amount: model.Cents(2300, "USD")},
+			},
+		},
+		{
+			name: "region without a rate", country: "US", region: "OR",
+			want: []model.TaxLine{},
+		},
+		{
+			name: "country without a rate", country: "JP", region: "Tokyo",
+			want: []model.TaxLine{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := model.UserAddress{Country: tt.country, StateProvinceRegion: tt.region}
+			got := table.Calculate(address, subtotal, shipping)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Calculate(%s, %s) = %+v, want %+v", tt.country, tt.region, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+-- Records which seller listing each order line was fulfilled from.
+ALTER TABLE order_items
+    ADD COLUMN inventory_id INT NULL AFTER item_id,
+    ADD CONSTRAINT fk_order_items_inventory
+        FOREIGN KEY (inventory_id) REFERENCES item_inventory (id) ON DELETE SET NULL;
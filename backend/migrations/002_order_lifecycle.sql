@@ -0,0 +1,22 @@
+-- Order lifecycle: statuses are now driven by internal/order.
+ALTER TABLE orders
+    MODIFY COLUMN status VARCHAR(32) NOT NULL DEFAULT 'pending_payment';
+
+UPDATE orders SET status = 'paid' WHERE status = 'Completed';
+
+CREATE TABLE order_status_transitions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    from_status VARCHAR(32) NULL,
+    to_status VARCHAR(32) NOT NULL,
+    actor_user_id INT NULL,
+    note VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_order_status_transitions_order (order_id, created_at),
+    CONSTRAINT fk_order_status_transitions_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_order_status_transitions_actor FOREIGN KEY (actor_user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- Staff accounts may move orders through any legal transition.
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'customer';
@@ -0,0 +1,18 @@
+-- Cards are tokenized by the payment provider; only the token is stored.
+ALTER TABLE user_payment_methods
+    ADD COLUMN provider_token VARCHAR(255) NULL AFTER provider;
+
+CREATE TABLE payments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    payment_method_id INT NULL,
+    provider VARCHAR(50) NOT NULL,
+    authorization_id VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    refunded_amount DECIMAL(10, 2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_payments_order (order_id),
+    CONSTRAINT fk_payments_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_payments_method FOREIGN KEY (payment_method_id) REFERENCES user_payment_methods (id) ON DELETE SET NULL
+);
@@ -0,0 +1,28 @@
+-- Refund ledger. Each refund lists the order lines and units it returned.
+ALTER TABLE order_items
+    ADD COLUMN refunded_quantity INT NOT NULL DEFAULT 0 AFTER quantity;
+
+CREATE TABLE refunds (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    payment_id INT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    reason VARCHAR(255) NULL,
+    provider_refund_id VARCHAR(255) NULL,
+    actor_user_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_refunds_order (order_id),
+    CONSTRAINT fk_refunds_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_refunds_payment FOREIGN KEY (payment_id) REFERENCES payments (id) ON DELETE SET NULL,
+    CONSTRAINT fk_refunds_actor FOREIGN KEY (actor_user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE refund_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    refund_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    quantity INT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    CONSTRAINT fk_refund_items_refund FOREIGN KEY (refund_id) REFERENCES refunds (id) ON DELETE CASCADE,
+    CONSTRAINT fk_refund_items_order_item FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE CASCADE
+);
@@ -0,0 +1,35 @@
+-- Platform commission per category. The row with a NULL category_id is the
+-- default for categories without their own rate.
+CREATE TABLE category_fees (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    category_id INT NULL,
+    commission_rate DECIMAL(5, 4) NOT NULL,
+    UNIQUE KEY uq_category_fees_category (category_id),
+    CONSTRAINT fk_category_fees_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
+);
+
+INSERT INTO category_fees (category_id, commission_rate) VALUES (NULL, 0.1000);
+
+-- Double-entry ledger. Entries of one transaction sum to zero; positive
+-- amounts are debits, negative amounts credits.
+CREATE TABLE ledger_transactions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    order_id INT NULL,
+    order_item_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_ledger_transactions_order_item (order_item_id),
+    CONSTRAINT fk_ledger_transactions_order FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_ledger_transactions_order_item FOREIGN KEY (order_item_id) REFERENCES order_items (id)
+);
+
+CREATE TABLE ledger_entries (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    transaction_id INT NOT NULL,
+    account VARCHAR(32) NOT NULL,
+    user_id INT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+    INDEX idx_ledger_entries_account_user (account, user_id),
+    CONSTRAINT fk_ledger_entries_transaction FOREIGN KEY (transaction_id) REFERENCES ledger_transactions (id),
+    CONSTRAINT fk_ledger_entries_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,3 @@
+-- Fixed per-sale processing fee, charged on top of the commission.
+ALTER TABLE category_fees
+    ADD COLUMN processing_fee DECIMAL(10, 2) NOT NULL DEFAULT 0.00 AFTER commission_rate;
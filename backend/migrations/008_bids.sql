@@ -0,0 +1,25 @@
+-- Buyer bids. An open bid is matched against the lowest ask for the same
+-- item and size; a filled bid points at the order it created.
+CREATE TABLE bids (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    item_id INT NOT NULL,
+    size_id INT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'open',
+    payment_method_id INT NULL,
+    shipping_address_id INT NULL,
+    expires_at DATETIME NOT NULL,
+    order_id INT NULL,
+    filled_price DECIMAL(10, 2) NULL,
+    filled_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_bids_book (item_id, size_id, status, price),
+    INDEX idx_bids_user (user_id, created_at),
+    CONSTRAINT fk_bids_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_bids_item FOREIGN KEY (item_id) REFERENCES items (id) ON DELETE CASCADE,
+    CONSTRAINT fk_bids_size FOREIGN KEY (size_id) REFERENCES sizes (id),
+    CONSTRAINT fk_bids_payment_method FOREIGN KEY (payment_method_id) REFERENCES user_payment_methods (id) ON DELETE SET NULL,
+    CONSTRAINT fk_bids_shipping_address FOREIGN KEY (shipping_address_id) REFERENCES user_addresses (id) ON DELETE SET NULL,
+    CONSTRAINT fk_bids_order FOREIGN KEY (order_id) REFERENCES orders (id)
+);
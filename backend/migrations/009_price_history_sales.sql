@@ -0,0 +1,10 @@
+-- Every completed sale is written to price_history, so each sale row records
+-- the size, the seller (NULL for store stock) and the order line it came from.
+ALTER TABLE price_history
+    ADD COLUMN size_id INT NULL AFTER price,
+    ADD COLUMN seller_user_id INT NULL AFTER size_id,
+    ADD COLUMN order_item_id INT NULL AFTER seller_user_id,
+    ADD INDEX idx_price_history_item_type (item_id, type, recorded_at),
+    ADD CONSTRAINT fk_price_history_size FOREIGN KEY (size_id) REFERENCES sizes (id),
+    ADD CONSTRAINT fk_price_history_seller FOREIGN KEY (seller_user_id) REFERENCES users (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_price_history_order_item FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE SET NULL;
@@ -0,0 +1,7 @@
+-- Sales recorded outside checkout are audited: source says where the row came
+-- from and recorded_by_user_id who entered it.
+ALTER TABLE price_history
+    ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT 'order' AFTER order_item_id,
+    ADD COLUMN recorded_by_user_id INT NULL AFTER source,
+    ADD INDEX idx_price_history_recorder (recorded_by_user_id, recorded_at),
+    ADD CONSTRAINT fk_price_history_recorder FOREIGN KEY (recorded_by_user_id) REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,14 @@
+-- Price rounding policies (see internal/pricing). The most specific row for a
+-- category and currency wins; the row with both NULL is the default. Policies
+-- are 'none', 'ceil_N' (e.g. 'ceil_10') or 'psychological'.
+CREATE TABLE rounding_policies (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    category_id INT NULL,
+    currency CHAR(3) NULL,
+    policy VARCHAR(32) NOT NULL,
+    UNIQUE KEY uq_rounding_policies_scope (category_id, currency),
+    CONSTRAINT fk_rounding_policies_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
+);
+
+-- The default keeps the round-up-to-10 prices the item pages always showed.
+INSERT INTO rounding_policies (category_id, currency, policy) VALUES (NULL, NULL, 'ceil_10');
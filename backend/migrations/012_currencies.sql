@@ -0,0 +1,26 @@
+-- Multi-currency. Listings, bids, orders and payments carry the currency they
+-- settle in. Retail prices, price_history and the seller ledger stay in the
+-- base currency (USD); sales in other currencies are converted at the stored
+-- rate when they are recorded.
+CREATE TABLE exchange_rates (
+    currency CHAR(3) PRIMARY KEY,
+    -- Units of this currency per 1 USD.
+    rate DECIMAL(18, 8) NOT NULL,
+    updated_by_user_id INT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_exchange_rates_user FOREIGN KEY (updated_by_user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+INSERT INTO exchange_rates (currency, rate) VALUES ('USD', 1);
+
+ALTER TABLE item_inventory
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER price;
+
+ALTER TABLE bids
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER price;
+
+ALTER TABLE orders
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER total_amount;
+
+ALTER TABLE payments
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER amount;
@@ -0,0 +1,44 @@
+-- Tax on orders (see internal/tax). Rates are looked up by the shipping
+-- address: rows with no region apply to the whole country and regional rows
+-- stack on top. rate is a fraction, e.g. 0.2 for 20% VAT.
+CREATE TABLE tax_rates (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    -- ISO 3166-1 alpha-2 country code.
+    country CHAR(2) NOT NULL,
+    -- State or province code as customers write it on their address, e.g. 'CA'.
+    region VARCHAR(64) NULL,
+    rate DECIMAL(7, 6) NOT NULL,
+    includes_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE KEY uq_tax_rates_scope (country, region, name)
+);
+
+INSERT INTO tax_rates (name, country, region, rate, includes_shipping) VALUES
+    ('VAT', 'GB', NULL, 0.20, TRUE),
+    ('VAT', 'DE', NULL, 0.19, TRUE),
+    ('VAT', 'FR', NULL, 0.20, TRUE),
+    ('Sales tax', 'US', 'CA', 0.0725, FALSE),
+    ('Sales tax', 'US', 'NY', 0.04, FALSE),
+    ('Sales tax', 'US', 'TX', 0.0625, FALSE);
+
+ALTER TABLE orders
+    ADD COLUMN subtotal_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER user_id,
+    ADD COLUMN tax_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER subtotal_amount,
+    ADD COLUMN shipping_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER tax_amount,
+    ADD COLUMN shipping_address_id INT NULL AFTER status,
+    ADD CONSTRAINT fk_orders_shipping_address FOREIGN KEY (shipping_address_id) REFERENCES user_addresses (id) ON DELETE SET NULL;
+
+UPDATE orders SET subtotal_amount = total_amount;
+
+CREATE TABLE order_tax_lines (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    country CHAR(2) NOT NULL,
+    region VARCHAR(64) NULL,
+    rate DECIMAL(7, 6) NOT NULL,
+    taxable_amount DECIMAL(10, 2) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    CONSTRAINT fk_order_tax_lines_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    INDEX idx_order_tax_lines_order (order_id)
+);
@@ -0,0 +1,89 @@
+-- Shipping (see internal/shipping). Buyers pay for delivery by method, priced
+-- by destination country and the weight class of the heaviest item in the
+-- order. Rates are in the base currency; a rate with no country applies to
+-- every country without a rate of its own.
+ALTER TABLE categories
+    ADD COLUMN weight_class VARCHAR(16) NOT NULL DEFAULT 'standard';
+
+CREATE TABLE shipping_methods (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(64) NOT NULL,
+    carrier VARCHAR(64) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE shipping_rates (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    method_id INT NOT NULL,
+    -- ISO 3166-1 alpha-2 country code.
+    country CHAR(2) NULL,
+    -- 'small', 'standard' or 'large'.
+    weight_class VARCHAR(16) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    UNIQUE KEY uq_shipping_rates_scope (method_id, country, weight_class),
+    CONSTRAINT fk_shipping_rates_method FOREIGN KEY (method_id) REFERENCES shipping_methods (id) ON DELETE CASCADE
+);
+
+INSERT INTO shipping_methods (id, code, name, carrier) VALUES
+    (1, 'standard', 'Standard', 'UPS'),
+    (2, 'express', 'Express', 'FedEx');
+
+INSERT INTO shipping_rates (method_id, country, weight_class, price) VALUES
+    (1, 'US', 'small', 7.00),
+    (1, 'US', 'standard', 14.50),
+    (1, 'US', 'large', 22.00),
+    (1, NULL, 'small', 18.00),
+    (1, NULL, 'standard', 32.00),
+    (1, NULL, 'large', 48.00),
+    (2, 'US', 'small', 19.00),
+    (2, 'US', 'standard', 29.00),
+    (2, 'US', 'large', 45.00),
+    (2, NULL, 'small', 39.00),
+    (2, NULL, 'standard', 59.00),
+    (2, NULL, 'large', 85.00);
+
+ALTER TABLE orders
+    ADD COLUMN shipping_method_id INT NULL AFTER shipping_address_id,
+    ADD CONSTRAINT fk_orders_shipping_method FOREIGN KEY (shipping_method_id) REFERENCES shipping_methods (id);
+
+-- A shipment is one parcel for an order: 'inbound' from a seller to
+-- authentication, 'outbound' from us to the buyer, or 'return' back to a
+-- seller.
+CREATE TABLE shipments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    seller_user_id INT NULL,
+    carrier VARCHAR(64) NOT NULL,
+    tracking_number VARCHAR(128) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    created_by_user_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_shipments_tracking (carrier, tracking_number),
+    INDEX idx_shipments_order (order_id),
+    CONSTRAINT fk_shipments_order FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    CONSTRAINT fk_shipments_seller FOREIGN KEY (seller_user_id) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_shipments_created_by FOREIGN KEY (created_by_user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE shipment_items (
+    shipment_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    PRIMARY KEY (shipment_id, order_item_id),
+    CONSTRAINT fk_shipment_items_shipment FOREIGN KEY (shipment_id) REFERENCES shipments (id) ON DELETE CASCADE,
+    CONSTRAINT fk_shipment_items_order_item FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE CASCADE
+);
+
+CREATE TABLE shipment_events (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    shipment_id INT NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    description VARCHAR(255) NULL,
+    location VARCHAR(128) NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_shipment_events_shipment (shipment_id, occurred_at),
+    CONSTRAINT fk_shipment_events_shipment FOREIGN KEY (shipment_id) REFERENCES shipments (id) ON DELETE CASCADE
+);
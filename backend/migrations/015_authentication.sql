@@ -0,0 +1,25 @@
+-- Authentication (see internal/authentication). Every sold line is inspected
+-- once before it ships to the buyer. A failed line is refunded and sent back
+-- to its seller.
+CREATE TABLE authentications (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_item_id INT NOT NULL UNIQUE,
+    verdict VARCHAR(8) NOT NULL,
+    notes TEXT NULL,
+    authenticator_user_id INT NULL,
+    refund_id INT NULL,
+    return_shipment_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_authentications_order_item FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE CASCADE,
+    CONSTRAINT fk_authentications_authenticator FOREIGN KEY (authenticator_user_id) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_authentications_refund FOREIGN KEY (refund_id) REFERENCES refunds (id),
+    CONSTRAINT fk_authentications_return_shipment FOREIGN KEY (return_shipment_id) REFERENCES shipments (id)
+);
+
+-- Photo references are URLs of images kept in our media storage.
+CREATE TABLE authentication_photos (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    authentication_id INT NOT NULL,
+    url VARCHAR(512) NOT NULL,
+    CONSTRAINT fk_authentication_photos_authentication FOREIGN KEY (authentication_id) REFERENCES authentications (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Cart lines can hold more than one unit of a listing. Order lines already
+-- carry a quantity.
+ALTER TABLE cart_items
+    ADD COLUMN quantity INT NOT NULL DEFAULT 1 AFTER inventory_id;
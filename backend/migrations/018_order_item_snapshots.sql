@@ -0,0 +1,18 @@
+-- Order lines keep the seller and size they were bought with, so both survive
+-- the listing being edited or deleted. size_id is NULL for one-size items;
+-- size is the label the buyer saw and is NULL only on lines whose listing was
+-- deleted before this migration.
+ALTER TABLE order_items
+    ADD COLUMN seller_user_id INT NULL AFTER inventory_id,
+    ADD COLUMN size_id INT NULL AFTER seller_user_id,
+    ADD COLUMN size VARCHAR(50) NULL AFTER size_id,
+    ADD INDEX idx_order_items_seller (seller_user_id),
+    ADD CONSTRAINT fk_order_items_seller FOREIGN KEY (seller_user_id) REFERENCES users (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_order_items_size FOREIGN KEY (size_id) REFERENCES sizes (id);
+
+UPDATE order_items oi
+JOIN item_inventory ii ON oi.inventory_id = ii.id
+LEFT JOIN sizes s ON ii.size_id = s.id
+SET oi.seller_user_id = ii.user_id,
+    oi.size_id = ii.size_id,
+    oi.size = COALESCE(s.size_value, 'One Size');
@@ -0,0 +1,10 @@
+-- The asking price of the listing each order line was bought from, in the
+-- order currency. A bid can fill above the ask, so it may differ from
+-- price_at_purchase. Older lines only know what was paid.
+ALTER TABLE order_items
+    ADD COLUMN listing_price DECIMAL(10, 2) NULL AFTER price_at_purchase;
+
+UPDATE order_items SET listing_price = price_at_purchase;
+
+ALTER TABLE order_items
+    MODIFY COLUMN listing_price DECIMAL(10, 2) NOT NULL;
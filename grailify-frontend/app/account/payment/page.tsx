@@ -115,7 +115,7 @@ const PaymentCard = ({ paymentMethod, onDelete }: { paymentMethod: PaymentMethod
 );
 
 const PaymentFormModal = ({ onClose, onPaymentAdded }: { onClose: () => void, onPaymentAdded: () => void }) => {
-    const [formData, setFormData] = useState({ cardType: 'Visa', lastFourDigits: '', expiryMonth: '', expiryYear: '' });
+    const [formData, setFormData] = useState({ cardNumber: '', expiryMonth: '', expiryYear: '' });
     const [error, setError] = useState('');
     const [isLoading, setIsLoading] = useState(false);
 
@@ -145,12 +145,11 @@ const PaymentFormModal = ({ onClose, onPaymentAdded }: { onClose: () => void, on
                 <div className="p-6">
                     <div className="flex justify-between items-center mb-4"><h2 className="text-xl font-semibold">Add New Card</h2><button onClick={onClose}><CloseIcon /></button></div>
                     <form onSubmit={handleSubmit} className="space-y-4">
-                        <input type="text" onChange={e => setFormData({...formData, lastFourDigits: e.target.value.slice(-4)})} placeholder="Card Number" required maxLength={19} className="w-full px-3 py-2 border rounded-md" />
+                        <input type="text" onChange={e => setFormData({...formData, cardNumber: e.target.value.replace(/\s/g, '')})} placeholder="Card Number" required maxLength={19} className="w-full px-3 py-2 border rounded-md" />
                         <div className="grid grid-cols-2 gap-4">
                             <input type="text" onChange={e => setFormData({...formData, expiryMonth: e.target.value})} placeholder="MM" required maxLength={2} className="w-full px-3 py-2 border rounded-md" />
                             <input type="text" onChange={e => setFormData({...formData, expiryYear: e.target.value})} placeholder="YYYY" required maxLength={4} className="w-full px-3 py-2 border rounded-md" />
                         </div>
-                        <select onChange={e => setFormData({...formData, cardType: e.target.value})} className="w-full px-3 py-2 border rounded-md bg-white"><option>Visa</option><option>MasterCard</option></select>
                         {error && <p className="text-sm text-red-500">{error}</p>}
                         <div className="flex justify-end space-x-3 pt-4">
                             <button type="button" onClick={onClose} className="px-4 py-2 rounded-lg border">Cancel</button>
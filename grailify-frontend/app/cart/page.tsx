@@ -12,34 +12,88 @@ interface CartItem {
     size: string;
     price: number;
     imageUrl: string;
+    // Set for lines of the account's cart on the server.
+    lineId?: number;
+    quantity?: number;
+    status?: string;
 }
 
+const statusLabels: Record<string, string> = {
+    sold_out: 'Sold out',
+    unavailable: 'No longer listed',
+    insufficient_stock: 'Not enough stock for this quantity',
+    price_changed: 'Price changed',
+};
+
 export default function CartPage() {
     const [cartItems, setCartItems] = useState<CartItem[]>([]);
+    const [subtotal, setSubtotal] = useState(0);
+    const [error, setError] = useState('');
     const router = useRouter();
 
-    useEffect(() => {
-        const storedCart = localStorage.getItem('grailifyCart');
-        if (storedCart) {
-            setCartItems(JSON.parse(storedCart));
+    const loadCart = async () => {
+        const token = localStorage.getItem('authToken');
+        if (!token) {
+            const storedCart = localStorage.getItem('grailifyCart');
+            const items: CartItem[] = storedCart ? JSON.parse(storedCart) : [];
+            setCartItems(items);
+            setSubtotal(items.reduce((sum, item) => sum + item.price, 0));
+            return;
+        }
+        try {
+            const response = await fetch('http://localhost:8080/api/cart', {
+                headers: { 'Authorization': `Bearer ${token}` }
+            });
+            if (!response.ok) throw new Error('Could not load your cart.');
+            const data = await response.json();
+            setCartItems(data.items.map((line: any) => ({
+                id: line.itemId,
+                inventoryId: line.inventoryId,
+                name: line.name,
+                brand: line.brand,
+                size: line.size,
+                price: line.price,
+                imageUrl: line.imageUrl,
+                lineId: line.id,
+                quantity: line.quantity,
+                status: line.status,
+            })));
+            setSubtotal(data.subtotal);
+        } catch (err: any) {
+            setError(err.message);
         }
+    };
+
+    useEffect(() => {
+        loadCart();
     }, []);
 
-    const removeFromCart = (inventoryIdToRemove: number) => {
-        const updatedCart = cartItems.filter(item => item.inventoryId !== inventoryIdToRemove);
-        setCartItems(updatedCart);
-        localStorage.setItem('grailifyCart', JSON.stringify(updatedCart));
+    const removeFromCart = async (item: CartItem) => {
+        const token = localStorage.getItem('authToken');
+        if (token && item.lineId) {
+            const response = await fetch(`http://localhost:8080/api/cart/items/${item.lineId}`, {
+                method: 'DELETE',
+                headers: { 'Authorization': `Bearer ${token}` }
+            });
+            if (!response.ok) {
+                setError('Could not remove this item.');
+                return;
+            }
+            await loadCart();
+        } else {
+            const updatedCart = cartItems.filter(cartItem => cartItem.inventoryId !== item.inventoryId);
+            setCartItems(updatedCart);
+            setSubtotal(updatedCart.reduce((sum, cartItem) => sum + cartItem.price, 0));
+            localStorage.setItem('grailifyCart', JSON.stringify(updatedCart));
+        }
+        window.dispatchEvent(new Event('cartUpdated'));
     };
 
-    const subtotal = cartItems.reduce((sum, item) => sum + item.price, 0);
-    const shipping = 15.00;
-    const taxes = subtotal * 0.08; // 8% tax example
-    const total = subtotal + shipping + taxes;
-
     return (
         <div className="bg-white">
             <div className="container mx-auto px-4 py-12">
                 <h1 className="text-4xl font-bold text-center text-black tracking-tight mb-12">Your Cart</h1>
+                {error && <p className="text-red-600 text-sm text-center mb-4">{error}</p>}
                 
                 {cartItems.length === 0 ? (
                     <div className="text-center py-20">
@@ -52,15 +106,19 @@ export default function CartPage() {
                     <div className="grid grid-cols-1 lg:grid-cols-3 gap-8 lg:gap-16">
                         <div className="lg:col-span-2 space-y-4">
                             {cartItems.map((item) => (
-                                <div key={item.inventoryId} className="flex items-center p-4 border border-neutral-200 rounded-lg">
+                                <div key={item.lineId ?? item.inventoryId} className="flex items-center p-4 border border-neutral-200 rounded-lg">
                                     <img src={item.imageUrl} alt={item.name} className="w-24 h-24 object-contain bg-neutral-100 rounded-md" />
                                     <div className="ml-4 flex-grow">
                                         <p className="font-semibold text-black">{item.name}</p>
                                         <p className="text-sm text-neutral-500">{item.brand}</p>
                                         <p className="text-sm text-neutral-500">Size: {item.size}</p>
+                                        {item.quantity && item.quantity > 1 && <p className="text-sm text-neutral-500">Quantity: {item.quantity}</p>}
                                         <p className="mt-2 font-bold text-black">${item.price.toFixed(2)}</p>
+                                        {item.status && statusLabels[item.status] && (
+                                            <p className="text-sm text-red-600">{statusLabels[item.status]}</p>
+                                        )}
                                     </div>
-                                    <button onClick={() => removeFromCart(item.inventoryId)} className="text-sm text-red-600 hover:underline">
+                                    <button onClick={() => removeFromCart(item)} className="text-sm text-red-600 hover:underline">
                                         Remove
                                     </button>
                                 </div>
@@ -71,10 +129,7 @@ export default function CartPage() {
                              <h2 className="text-xl font-semibold mb-4">Order Summary</h2>
                             <div className="space-y-4">
                                 <div className="flex justify-between text-neutral-600"><span>Subtotal</span><span>${subtotal.toFixed(2)}</span></div>
-                                <div className="flex justify-between text-neutral-600"><span>Shipping</span><span>${shipping.toFixed(2)}</span></div>
-                                <div className="flex justify-between text-neutral-600"><span>Taxes</span><span>${taxes.toFixed(2)}</span></div>
-                                <div className="border-t border-neutral-200 my-4"></div>
-                                <div className="flex justify-between font-bold text-black text-lg"><span>Total</span><span>${total.toFixed(2)}</span></div>
+                                <p className="text-sm text-neutral-500">Tax and shipping are calculated at checkout from your shipping address.</p>
                             </div>
                             <div className="mt-6">
                                 <button onClick={() => router.push('/checkout')} className="w-full bg-black text-white py-3 rounded-lg font-semibold hover:bg-neutral-800 transition-colors">
@@ -87,4 +142,4 @@ export default function CartPage() {
             </div>
         </div>
     );
-}
\ No newline at end of file
+}
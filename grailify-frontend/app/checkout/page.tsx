@@ -5,26 +5,38 @@ import { useRouter } from 'next/navigation';
 import Link from 'next/link';
 import OrderSuccessModal from '@/components/modals/OrderSuccessModal'; 
 
-type CartItem = { id: number; inventoryId: number; name: string; brand: string; size: string; price: number; imageUrl: string; };
+type CartItem = { id: number; inventoryId: number; name: string; brand: string; size: string; price: number; currency: string; quantity: number; imageUrl: string; };
 type Address = { id: number; fullName: string; addressLine1: string; };
 type PaymentMethod = { id: number; cardType: string; lastFourDigits: string; };
 type ProfileData = { addresses: Address[]; paymentMethods: PaymentMethod[]; };
+type ShippingOption = { methodId: number; name: string; carrier: string; price: number; };
+type TaxLine = { name: string; amount: number; };
+type Quote = {
+    subtotal: number;
+    tax: number;
+    shipping: number;
+    total: number;
+    currency: string;
+    taxLines: TaxLine[];
+    shippingMethod: ShippingOption;
+    shippingOptions: ShippingOption[];
+};
 
 export default function CheckoutPage() {
     const router = useRouter();
     const [cartItems, setCartItems] = useState<CartItem[]>([]);
+    const [skippedCount, setSkippedCount] = useState(0);
     const [profileData, setProfileData] = useState<ProfileData | null>(null);
     const [selectedAddressId, setSelectedAddressId] = useState<string>('');
     const [selectedPaymentId, setSelectedPaymentId] = useState<string>('');
+    const [selectedMethodId, setSelectedMethodId] = useState<number>(0);
+    const [quote, setQuote] = useState<Quote | null>(null);
     const [isLoading, setIsLoading] = useState(true);
     const [isPlacingOrder, setIsPlacingOrder] = useState(false);
     const [error, setError] = useState('');
     const [orderSuccessInfo, setOrderSuccessInfo] = useState<{ orderId: number | string } | null>(null);
 
     useEffect(() => {
-        const storedCart = localStorage.getItem('grailifyCart');
-        setCartItems(storedCart ? JSON.parse(storedCart) : []);
-
         const token = localStorage.getItem('authToken');
         if (!token) {
             router.push('/login');
@@ -33,6 +45,27 @@ export default function CheckoutPage() {
 
         const fetchProfileData = async () => {
             try {
+                // Lines that can no longer be bought stay in the cart but are
+                // left out of the order.
+                const cartResponse = await fetch('http://localhost:8080/api/cart', {
+                    headers: { 'Authorization': `Bearer ${token}` }
+                });
+                if (!cartResponse.ok) throw new Error('Could not load your cart.');
+                const cart = await cartResponse.json();
+                const buyable = cart.items.filter((line: any) => line.status !== 'sold_out' && line.status !== 'unavailable');
+                setSkippedCount(cart.items.length - buyable.length);
+                setCartItems(buyable.map((line: any) => ({
+                    id: line.itemId,
+                    inventoryId: line.inventoryId,
+                    name: line.name,
+                    brand: line.brand,
+                    size: line.size,
+                    price: line.price,
+                    currency: line.currency,
+                    quantity: line.quantity,
+                    imageUrl: line.imageUrl,
+                })));
+
                 const response = await fetch('http://localhost:8080/api/profile', {
                     headers: { 'Authorization': `Bearer ${token}` }
                 });
@@ -55,12 +88,50 @@ export default function CheckoutPage() {
         fetchProfileData();
     }, [router]);
 
-    const subtotal = cartItems.reduce((sum, item) => sum + item.price, 0);
-    const shipping = 15.00;
-    const taxes = subtotal * 0.08;
-    const total = subtotal + shipping + taxes;
+    // Tax and shipping depend on the address, so the server prices the
+    // order whenever the cart, address or shipping method changes.
+    useEffect(() => {
+        if (!selectedAddressId || cartItems.length === 0) {
+            setQuote(null);
+            return;
+        }
+        const token = localStorage.getItem('authToken');
+        let cancelled = false;
+
+        const fetchQuote = async () => {
+            try {
+                const response = await fetch('http://localhost:8080/api/checkout/quote', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${token}` },
+                    body: JSON.stringify({
+                        cartItems,
+                        shippingAddressId: parseInt(selectedAddressId),
+                        shippingMethodId: selectedMethodId,
+                    }),
+                });
+                const data = await response.json().catch(() => ({}));
+                if (!response.ok) throw new Error(data.message || 'Could not price your order.');
+                if (!cancelled) {
+                    setQuote(data);
+                    setError('');
+                }
+            } catch (err: any) {
+                if (!cancelled) {
+                    setQuote(null);
+                    setError(err.message);
+                }
+            }
+        };
+
+        fetchQuote();
+        return () => { cancelled = true; };
+    }, [cartItems, selectedAddressId, selectedMethodId]);
 
     const handlePlaceOrder = async () => {
+        if (!quote) {
+            setError('Your order could not be priced yet.');
+            return;
+        }
         if (!selectedAddressId || !selectedPaymentId) {
             setError('Please select a shipping address and payment method.');
             return;
@@ -74,9 +145,10 @@ export default function CheckoutPage() {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${token}` },
                 body: JSON.stringify({
-                    cartItems: cartItems,
-                    totalAmount: total,
+                    cartItems,
+                    totalAmount: quote.total,
                     shippingAddressId: parseInt(selectedAddressId),
+                    shippingMethodId: quote.shippingMethod.methodId,
                     paymentMethodId: parseInt(selectedPaymentId),
                 }),
             });
@@ -86,7 +158,7 @@ export default function CheckoutPage() {
                 throw new Error(data.message || 'Failed to place order.');
             }
 
-            localStorage.removeItem('grailifyCart');
+            window.dispatchEvent(new Event('cartUpdated'));
             setOrderSuccessInfo({ orderId: data.orderId });
 
         } catch (err: any) {
@@ -116,7 +188,7 @@ export default function CheckoutPage() {
                             <div className="border border-neutral-200 rounded-lg p-6">
                                 <h2 className="text-xl font-semibold mb-4">Shipping Address</h2>
                                 {profileData && profileData.addresses && profileData.addresses.length > 0 ? (
-                                    <select value={selectedAddressId} onChange={e => setSelectedAddressId(e.target.value)} className="w-full p-3 border rounded-md bg-white">
+                                    <select value={selectedAddressId} onChange={e => { setSelectedAddressId(e.target.value); setSelectedMethodId(0); }} className="w-full p-3 border rounded-md bg-white">
                                         {profileData.addresses.map(addr => (
                                             <option key={addr.id} value={addr.id}>
                                                 {addr.fullName} - {addr.addressLine1}
@@ -126,6 +198,19 @@ export default function CheckoutPage() {
                                 ) : <p>No addresses found. <Link href="/account/addresses" className="text-blue-600">Add one</Link>.</p>}
                             </div>
 
+                            {quote && quote.shippingOptions.length > 0 && (
+                                <div className="border border-neutral-200 rounded-lg p-6">
+                                    <h2 className="text-xl font-semibold mb-4">Shipping Method</h2>
+                                    <select value={quote.shippingMethod.methodId} onChange={e => setSelectedMethodId(parseInt(e.target.value))} className="w-full p-3 border rounded-md bg-white">
+                                        {quote.shippingOptions.map(option => (
+                                            <option key={option.methodId} value={option.methodId}>
+                                                {option.name} ({option.carrier}) - {option.price.toFixed(2)} {quote.currency}
+                                            </option>
+                                        ))}
+                                    </select>
+                                </div>
+                            )}
+
                             <div className="border border-neutral-200 rounded-lg p-6">
                                 <h2 className="text-xl font-semibold mb-4">Payment Method</h2>
                                 {profileData && profileData.paymentMethods && profileData.paymentMethods.length > 0 ? (
@@ -145,21 +230,30 @@ export default function CheckoutPage() {
                             <div className="space-y-2 mb-4">
                                 {cartItems.map(item => (
                                     <div key={item.inventoryId} className="flex justify-between text-sm">
-                                        <span>{item.name}</span>
-                                        <span>${item.price.toFixed(2)}</span>
+                                        <span>{item.name}{item.quantity > 1 ? ` × ${item.quantity}` : ''}</span>
+                                        <span>{(item.price * item.quantity).toFixed(2)} {item.currency}</span>
                                     </div>
                                 ))}
+                                {skippedCount > 0 && (
+                                    <p className="text-sm text-neutral-500">{skippedCount} item(s) in your cart are no longer available and were left out.</p>
+                                )}
                             </div>
-                            <div className="space-y-4 border-t pt-4">
-                                <div className="flex justify-between text-neutral-600"><span>Subtotal</span><span>${subtotal.toFixed(2)}</span></div>
-                                <div className="flex justify-between text-neutral-600"><span>Shipping</span><span>${shipping.toFixed(2)}</span></div>
-                                <div className="flex justify-between text-neutral-600"><span>Taxes</span><span>${taxes.toFixed(2)}</span></div>
-                                <div className="border-t border-neutral-200 my-4"></div>
-                                <div className="flex justify-between font-bold text-black text-lg"><span>Total</span><span>${total.toFixed(2)}</span></div>
-                            </div>
+                            {quote ? (
+                                <div className="space-y-4 border-t pt-4">
+                                    <div className="flex justify-between text-neutral-600"><span>Subtotal</span><span>{quote.subtotal.toFixed(2)} {quote.currency}</span></div>
+                                    <div className="flex justify-between text-neutral-600"><span>Shipping</span><span>{quote.shipping.toFixed(2)} {quote.currency}</span></div>
+                                    {quote.taxLines.map(line => (
+                                        <div key={line.name} className="flex justify-between text-neutral-600"><span>{line.name}</span><span>{line.amount.toFixed(2)} {quote.currency}</span></div>
+                                    ))}
+                                    <div className="border-t border-neutral-200 my-4"></div>
+                                    <div className="flex justify-between font-bold text-black text-lg"><span>Total</span><span>{quote.total.toFixed(2)} {quote.currency}</span></div>
+                                </div>
+                            ) : (
+                                <p className="border-t pt-4 text-sm text-neutral-600">Select a shipping address to see tax and shipping.</p>
+                            )}
                             <div className="mt-6">
                                 {error && <p className="text-red-600 text-sm text-center mb-4">{error}</p>}
-                                <button onClick={handlePlaceOrder} disabled={isPlacingOrder || cartItems.length === 0} className="w-full bg-black text-white py-3 rounded-lg font-semibold hover:bg-neutral-800 transition-colors disabled:bg-neutral-400">
+                                <button onClick={handlePlaceOrder} disabled={isPlacingOrder || cartItems.length === 0 || !quote} className="w-full bg-black text-white py-3 rounded-lg font-semibold hover:bg-neutral-800 transition-colors disabled:bg-neutral-400">
                                     {isPlacingOrder ? 'Placing Order...' : 'Place Order'}
                                 </button>
                             </div>
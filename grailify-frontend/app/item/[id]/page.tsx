@@ -209,7 +209,7 @@ export default function ItemPage() {
         setSelectedPrice(inventoryItem.price);
     };
 
-    const handleAddToCart = () => {
+    const handleAddToCart = async () => {
         if (!itemData) return;
         const selectedInventoryItem = itemData.inventory?.find(inv => inv.inventoryId === selectedInventoryId);
         if (itemData.inventory && itemData.inventory.length > 0 && !selectedInventoryItem) {
@@ -227,10 +227,25 @@ export default function ItemPage() {
             imageUrl: itemData.item.imageUrl
         };
 
-        const existingCart = JSON.parse(localStorage.getItem('grailifyCart') || '[]');
-        existingCart.push(cartItem);
-        localStorage.setItem('grailifyCart', JSON.stringify(existingCart));
-        
+        const token = localStorage.getItem('authToken');
+        if (token) {
+            const response = await fetch('http://localhost:8080/api/cart', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${token}` },
+                body: JSON.stringify({ inventoryId: cartItem.inventoryId, quantity: 1 }),
+            });
+            if (!response.ok) {
+                const data = await response.json().catch(() => ({}));
+                alert(data.message || 'Could not add this item to your cart.');
+                return;
+            }
+        } else {
+            const existingCart = JSON.parse(localStorage.getItem('grailifyCart') || '[]');
+            existingCart.push(cartItem);
+            localStorage.setItem('grailifyCart', JSON.stringify(existingCart));
+        }
+        window.dispatchEvent(new Event('cartUpdated'));
+
         setShowCartModal(true);
     };
 
@@ -20,10 +20,16 @@ export default function LoginPage() {
     setIsLoading(true);
 
     try {
+      // The cart kept while logged out is merged into the account's cart.
+      const guestCart = JSON.parse(localStorage.getItem('grailifyCart') || '[]');
       const response = await fetch(`http://localhost:8080/api/login`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ email, password }),
+        body: JSON.stringify({
+          email,
+          password,
+          cartItems: guestCart.map((item: any) => ({ ...item, quantity: 1 })),
+        }),
       });
 
       const data = await response.json();
@@ -33,7 +39,8 @@ export default function LoginPage() {
       }
       
       if (data.token) {
-        localStorage.setItem('authToken', data.token); 
+        localStorage.setItem('authToken', data.token);
+        localStorage.removeItem('grailifyCart');
         window.location.href = '/'; 
       } else {
         throw new Error('Login successful, but no token received.');
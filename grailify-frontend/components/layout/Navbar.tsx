@@ -27,9 +27,23 @@ export default function Navbar() {
     const token = localStorage.getItem('authToken');
     setIsLoggedIn(!!token);
 
-    const updateCart = () => {
-        const storedCart = localStorage.getItem('grailifyCart');
-        setCartItems(storedCart ? JSON.parse(storedCart) : []);
+    const updateCart = async () => {
+        const token = localStorage.getItem('authToken');
+        if (!token) {
+            const storedCart = localStorage.getItem('grailifyCart');
+            setCartItems(storedCart ? JSON.parse(storedCart) : []);
+            return;
+        }
+        try {
+            const response = await fetch('http://localhost:8080/api/cart', {
+                headers: { 'Authorization': `Bearer ${token}` }
+            });
+            if (!response.ok) return;
+            const data = await response.json();
+            setCartItems(data.items.map((line: any) => ({ id: line.itemId, name: line.name, price: line.price, imageUrl: line.imageUrl, size: line.size })));
+        } catch {
+            // Keep showing the last known cart.
+        }
     };
     updateCart();
 
@@ -55,6 +69,7 @@ export default function Navbar() {
   const handleLogout = () => {
     localStorage.removeItem('authToken');
     setIsLoggedIn(false);
+    setCartItems([]);
     router.push('/');
   };
 